@@ -0,0 +1,415 @@
+package unicreds
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// batchChunkSize is the max number of items DynamoDB allows per
+	// BatchGetItem/BatchWriteItem call.
+	batchChunkSize = 25
+
+	// batchKMSParallelism bounds how many concurrent KMS calls a batch
+	// operation will make while fanning out Decrypt/GenerateDataKey.
+	batchKMSParallelism = 8
+
+	// batchMaxRetries bounds how many times unprocessed keys/items are
+	// retried before giving up.
+	batchMaxRetries = 5
+
+	batchRetryBaseDelay = 50 * time.Millisecond
+)
+
+// PutRequest describes a single credential to write as part of a
+// BatchPutSecrets call. A zero Version resolves to "1" and a zero TTL means
+// the credential never expires, matching PutSecret.
+type PutRequest struct {
+	Alias   string
+	Name    string
+	Secret  string
+	Version string
+	TTL     time.Duration
+}
+
+// BatchGetSecrets retrieves the latest version of each named secret,
+// chunking the underlying BatchGetItem calls into groups of 25 and
+// decrypting the results concurrently. Names with no stored secret are
+// omitted from the result rather than causing an error.
+func BatchGetSecrets(ctx context.Context, tableName *string, names []string) (map[string]*DecryptedCredential, error) {
+	return defaultClient.BatchGetSecrets(ctx, tableName, names)
+}
+
+// BatchGetSecrets retrieves the latest version of each named secret,
+// chunking the underlying BatchGetItem calls into groups of 25 and
+// decrypting the results concurrently. Names with no stored secret are
+// omitted from the result rather than causing an error.
+func (c *Client) BatchGetSecrets(ctx context.Context, tableName *string, names []string) (map[string]*DecryptedCredential, error) {
+	log.WithField("count", len(names)).Debug("Batch getting secrets")
+
+	keys := c.resolveLatestKeys(ctx, tableName, names)
+
+	creds, err := c.batchGetItems(ctx, tableName, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*DecryptedCredential, len(creds))
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, batchKMSParallelism)
+		fn  error
+	)
+
+	for _, cred := range creds {
+		cred := cred
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			dcred, err := c.decryptCredential(ctx, cred)
+			if err != nil {
+				log.WithError(err).WithField("name", cred.Name).Error("failed to decrypt secret")
+				mu.Lock()
+				fn = err
+				mu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			results[cred.Name] = dcred
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if fn != nil {
+		return results, fn
+	}
+
+	return results, nil
+}
+
+// resolveLatestKeys looks up the highest version for each name, bounded by
+// batchKMSParallelism concurrent queries, and returns the primary keys of
+// the secrets that exist.
+func (c *Client) resolveLatestKeys(ctx context.Context, tableName *string, names []string) []map[string]types.AttributeValue {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, batchKMSParallelism)
+		keys []map[string]types.AttributeValue
+	)
+
+	for _, name := range names {
+		name := name
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			version, _, err := c.getHighestVersion(ctx, c.ddb, tableName, name)
+			if err != nil {
+				if err != ErrSecretNotFound {
+					log.WithError(err).WithField("name", name).Error("failed to resolve highest version")
+				}
+				return
+			}
+
+			mu.Lock()
+			keys = append(keys, map[string]types.AttributeValue{
+				"name":    &types.AttributeValueMemberS{Value: name},
+				"version": &types.AttributeValueMemberS{Value: version},
+			})
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return keys
+}
+
+// batchGetItems fetches keys in chunks of batchChunkSize, retrying any
+// UnprocessedKeys with exponential backoff.
+func (c *Client) batchGetItems(ctx context.Context, tableName *string, keys []map[string]types.AttributeValue) ([]*Credential, error) {
+	var creds []*Credential
+
+	for start := 0; start < len(keys); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		pending := keys[start:end]
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > batchMaxRetries {
+				return nil, ErrTimeout
+			}
+			if attempt > 0 {
+				time.Sleep(batchRetryBaseDelay * time.Duration(1<<uint(attempt-1)))
+			}
+
+			res, err := c.ddb.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: map[string]types.KeysAndAttributes{
+					aws.ToString(tableName): {Keys: pending},
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			decoded, err := decodeCredential(res.Responses[aws.ToString(tableName)])
+			if err != nil {
+				return nil, err
+			}
+			creds = append(creds, decoded...)
+
+			pending = res.UnprocessedKeys[aws.ToString(tableName)].Keys
+		}
+	}
+
+	return filterExpired(creds), nil
+}
+
+// BatchPutSecrets encrypts and stores each credential, generating a fresh
+// KMS data key per item (a GenerateDataKey result cannot be reused across
+// credentials under the envelope scheme PutSecret uses) with up to
+// batchKMSParallelism requests in flight at once, then writes the results
+// with BatchWriteItem in chunks of 25, retrying UnprocessedItems.
+func BatchPutSecrets(ctx context.Context, tableName *string, reqs []PutRequest) error {
+	return defaultClient.BatchPutSecrets(ctx, tableName, reqs)
+}
+
+// BatchPutSecrets encrypts and stores each credential, generating a fresh
+// KMS data key per item (a GenerateDataKey result cannot be reused across
+// credentials under the envelope scheme PutSecret uses) with up to
+// batchKMSParallelism requests in flight at once, then writes the results
+// with BatchWriteItem in chunks of 25, retrying UnprocessedItems.
+func (c *Client) BatchPutSecrets(ctx context.Context, tableName *string, reqs []PutRequest) error {
+	log.WithField("count", len(reqs)).Debug("Batch putting secrets")
+
+	items := make([]map[string]types.AttributeValue, len(reqs))
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, batchKMSParallelism)
+		mu   sync.Mutex
+		ferr error
+	)
+
+	for i, req := range reqs {
+		i, req := i, req
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			item, err := c.encodePutRequest(ctx, req)
+			if err != nil {
+				mu.Lock()
+				ferr = err
+				mu.Unlock()
+				return
+			}
+
+			items[i] = item
+		}()
+	}
+
+	wg.Wait()
+
+	if ferr != nil {
+		return ferr
+	}
+
+	for start := 0; start < len(items); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		pending := make([]types.WriteRequest, 0, end-start)
+		for _, item := range items[start:end] {
+			pending = append(pending, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		}
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > batchMaxRetries {
+				return ErrTimeout
+			}
+			if attempt > 0 {
+				time.Sleep(batchRetryBaseDelay * time.Duration(1<<uint(attempt-1)))
+			}
+
+			res, err := c.writeDdb.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{
+					aws.ToString(tableName): pending,
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			pending = res.UnprocessedItems[aws.ToString(tableName)]
+		}
+	}
+
+	return nil
+}
+
+// encodePutRequest mints a data key and encrypts a single PutRequest into a
+// DynamoDB item, mirroring PutSecret.
+func (c *Client) encodePutRequest(ctx context.Context, req PutRequest) (map[string]types.AttributeValue, error) {
+	kmsKey := DefaultKmsKey
+	if req.Alias != "" {
+		kmsKey = req.Alias
+	}
+
+	version := req.Version
+	if version == "" {
+		version = "1"
+	}
+
+	dk, err := c.GenerateDataKey(ctx, kmsKey, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := dk.Plaintext[:32]
+	hmacKey := dk.Plaintext[32:]
+	wrappedKey := dk.CiphertextBlob
+
+	ctext, err := Encrypt(dataKey, []byte(req.Secret))
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt int64
+	if req.TTL > 0 {
+		expiresAt = time.Now().Add(req.TTL).Unix()
+	}
+
+	cred := &Credential{
+		Name:      req.Name,
+		Version:   version,
+		Key:       base64.StdEncoding.EncodeToString(wrappedKey),
+		Contents:  base64.StdEncoding.EncodeToString(ctext),
+		Hmac:      ComputeHmac256(ctext, hmacKey),
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiresAt,
+	}
+
+	return Encode(cred)
+}
+
+// BatchDeleteSecrets deletes every version of each named secret, chunking
+// the underlying BatchWriteItem calls into groups of 25 and retrying
+// UnprocessedItems with exponential backoff.
+func BatchDeleteSecrets(ctx context.Context, tableName *string, names []string) error {
+	return defaultClient.BatchDeleteSecrets(ctx, tableName, names)
+}
+
+// BatchDeleteSecrets deletes every version of each named secret, chunking
+// the underlying BatchWriteItem calls into groups of 25 and retrying
+// UnprocessedItems with exponential backoff.
+func (c *Client) BatchDeleteSecrets(ctx context.Context, tableName *string, names []string) error {
+	log.WithField("count", len(names)).Debug("Batch deleting secrets")
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, batchKMSParallelism)
+		reqs    []types.WriteRequest
+		fetcher error
+	)
+
+	for _, name := range names {
+		name := name
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res, err := c.writeDdb.Query(ctx, &dynamodb.QueryInput{
+				TableName: tableName,
+				ExpressionAttributeNames: map[string]string{
+					"#N": "name",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":name": &types.AttributeValueMemberS{Value: name},
+				},
+				KeyConditionExpression: aws.String("#N = :name"),
+				ConsistentRead:         aws.Bool(true),
+				ProjectionExpression:   aws.String("#N, version"),
+			})
+			if err != nil {
+				mu.Lock()
+				fetcher = err
+				mu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			for _, item := range res.Items {
+				reqs = append(reqs, types.WriteRequest{
+					DeleteRequest: &types.DeleteRequest{Key: item},
+				})
+			}
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if fetcher != nil {
+		return fetcher
+	}
+
+	for start := 0; start < len(reqs); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+
+		pending := reqs[start:end]
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > batchMaxRetries {
+				return ErrTimeout
+			}
+			if attempt > 0 {
+				time.Sleep(batchRetryBaseDelay * time.Duration(1<<uint(attempt-1)))
+			}
+
+			res, err := c.writeDdb.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{
+					aws.ToString(tableName): pending,
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			pending = res.UnprocessedItems[aws.ToString(tableName)]
+		}
+	}
+
+	return nil
+}
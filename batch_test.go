@@ -0,0 +1,233 @@
+package unicreds
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// mockBatchDynamoDB implements DynamoDBAPI, panicking on any method a test
+// didn't stub out.
+type mockBatchDynamoDB struct {
+	DynamoDBAPI
+
+	batchGetItemFn   func(ctx context.Context, params *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
+	batchWriteItemFn func(ctx context.Context, params *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	queryFn          func(ctx context.Context, params *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+}
+
+func (m *mockBatchDynamoDB) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	return m.batchGetItemFn(ctx, params)
+}
+
+func (m *mockBatchDynamoDB) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	return m.batchWriteItemFn(ctx, params)
+}
+
+func (m *mockBatchDynamoDB) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return m.queryFn(ctx, params)
+}
+
+func credItem(t *testing.T, name, version string) map[string]types.AttributeValue {
+	t.Helper()
+
+	item, err := Encode(&Credential{Name: name, Version: version})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	return item
+}
+
+func TestBatchGetItemsChunksAt25(t *testing.T) {
+	keys := make([]map[string]types.AttributeValue, 40)
+	for i := range keys {
+		keys[i] = map[string]types.AttributeValue{
+			"name":    &types.AttributeValueMemberS{Value: "name"},
+			"version": &types.AttributeValueMemberS{Value: "1"},
+		}
+	}
+
+	var mu sync.Mutex
+	var chunkSizes []int
+
+	c := &Client{
+		ddb: &mockBatchDynamoDB{
+			batchGetItemFn: func(ctx context.Context, params *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+				reqKeys := params.RequestItems["table"].Keys
+
+				mu.Lock()
+				chunkSizes = append(chunkSizes, len(reqKeys))
+				mu.Unlock()
+
+				return &dynamodb.BatchGetItemOutput{
+					Responses: map[string][]map[string]types.AttributeValue{
+						"table": {credItem(t, "name", "1")},
+					},
+				}, nil
+			},
+		},
+	}
+
+	creds, err := c.batchGetItems(context.Background(), stringPtr("table"), keys)
+	if err != nil {
+		t.Fatalf("batchGetItems: %v", err)
+	}
+	if len(creds) != 2 {
+		t.Fatalf("expected one decoded credential per chunk, got %d", len(creds))
+	}
+	if len(chunkSizes) != 2 || chunkSizes[0] != batchChunkSize || chunkSizes[1] != 15 {
+		t.Fatalf("expected chunks of [25, 15], got %v", chunkSizes)
+	}
+}
+
+func TestBatchGetItemsRetriesUnprocessedKeys(t *testing.T) {
+	keys := []map[string]types.AttributeValue{
+		{"name": &types.AttributeValueMemberS{Value: "name"}, "version": &types.AttributeValueMemberS{Value: "1"}},
+	}
+
+	calls := 0
+	c := &Client{
+		ddb: &mockBatchDynamoDB{
+			batchGetItemFn: func(ctx context.Context, params *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+				calls++
+				if calls == 1 {
+					return &dynamodb.BatchGetItemOutput{
+						UnprocessedKeys: map[string]types.KeysAndAttributes{
+							"table": {Keys: params.RequestItems["table"].Keys},
+						},
+					}, nil
+				}
+
+				return &dynamodb.BatchGetItemOutput{
+					Responses: map[string][]map[string]types.AttributeValue{
+						"table": {credItem(t, "name", "1")},
+					},
+				}, nil
+			},
+		},
+	}
+
+	creds, err := c.batchGetItems(context.Background(), stringPtr("table"), keys)
+	if err != nil {
+		t.Fatalf("batchGetItems: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a retry after UnprocessedKeys, got %d calls", calls)
+	}
+	if len(creds) != 1 {
+		t.Fatalf("expected 1 credential, got %d", len(creds))
+	}
+}
+
+func TestBatchGetItemsGivesUpAfterMaxRetries(t *testing.T) {
+	keys := []map[string]types.AttributeValue{
+		{"name": &types.AttributeValueMemberS{Value: "name"}, "version": &types.AttributeValueMemberS{Value: "1"}},
+	}
+
+	c := &Client{
+		ddb: &mockBatchDynamoDB{
+			batchGetItemFn: func(ctx context.Context, params *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+				return &dynamodb.BatchGetItemOutput{
+					UnprocessedKeys: map[string]types.KeysAndAttributes{
+						"table": {Keys: params.RequestItems["table"].Keys},
+					},
+				}, nil
+			},
+		},
+	}
+
+	_, err := c.batchGetItems(context.Background(), stringPtr("table"), keys)
+	if err != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestBatchPutSecretsRetriesUnprocessedItems(t *testing.T) {
+	calls := 0
+	c := &Client{
+		writeDdb: &mockBatchDynamoDB{
+			batchWriteItemFn: func(ctx context.Context, params *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+				calls++
+				if calls == 1 {
+					return &dynamodb.BatchWriteItemOutput{
+						UnprocessedItems: map[string][]types.WriteRequest{
+							"table": params.RequestItems["table"],
+						},
+					}, nil
+				}
+				return &dynamodb.BatchWriteItemOutput{}, nil
+			},
+		},
+		kms: &mockBatchKMS{
+			generateDataKeyFn: func(ctx context.Context, params *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error) {
+				return &kms.GenerateDataKeyOutput{
+					Plaintext:      make([]byte, 64),
+					CiphertextBlob: []byte("wrapped"),
+				}, nil
+			},
+		},
+	}
+
+	reqs := []PutRequest{{Name: "foo", Secret: "s3cr3t"}}
+	if err := c.BatchPutSecrets(context.Background(), stringPtr("table"), reqs); err != nil {
+		t.Fatalf("BatchPutSecrets: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a retry after UnprocessedItems, got %d calls", calls)
+	}
+}
+
+func TestBatchDeleteSecretsChunksWrites(t *testing.T) {
+	items := make([]map[string]types.AttributeValue, 30)
+	for i := range items {
+		items[i] = credItem(t, "name", "1")
+	}
+
+	var mu sync.Mutex
+	var chunkSizes []int
+
+	c := &Client{
+		writeDdb: &mockBatchDynamoDB{
+			queryFn: func(ctx context.Context, params *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+				return &dynamodb.QueryOutput{Items: items}, nil
+			},
+			batchWriteItemFn: func(ctx context.Context, params *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+				mu.Lock()
+				chunkSizes = append(chunkSizes, len(params.RequestItems["table"]))
+				mu.Unlock()
+				return &dynamodb.BatchWriteItemOutput{}, nil
+			},
+		},
+	}
+
+	if err := c.BatchDeleteSecrets(context.Background(), stringPtr("table"), []string{"name"}); err != nil {
+		t.Fatalf("BatchDeleteSecrets: %v", err)
+	}
+
+	total := 0
+	for _, size := range chunkSizes {
+		if size > batchChunkSize {
+			t.Fatalf("expected chunks bounded at %d, got %d", batchChunkSize, size)
+		}
+		total += size
+	}
+	if total != len(items) {
+		t.Fatalf("expected every item written, got %d of %d", total, len(items))
+	}
+}
+
+// mockBatchKMS implements KMSAPI, panicking on any method a test didn't stub
+// out.
+type mockBatchKMS struct {
+	KMSAPI
+
+	generateDataKeyFn func(ctx context.Context, params *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error)
+}
+
+func (m *mockBatchKMS) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	return m.generateDataKeyFn(ctx, params)
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	encjson "encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/cli"
 	"github.com/apex/log/handlers/json"
 
+	"github.com/aws/aws-sdk-go-v2/config"
+
 	"github.com/MasteryConnect/unicreds"
+	"github.com/MasteryConnect/unicreds/server"
 	"github.com/alecthomas/kingpin"
 )
 
@@ -25,7 +34,13 @@ var (
 	alias       = app.Flag("alias", "KMS key alias.").Default("alias/credstash").Short('k').String()
 
 	// commands
-	cmdSetup = app.Command("setup", "Setup the dynamodb table used to store credentials.")
+	cmdSetup             = app.Command("setup", "Setup the dynamodb table used to store credentials.")
+	cmdSetupBilling      = cmdSetup.Flag("billing", "Table billing mode.").Default("provisioned").Enum("provisioned", "on-demand")
+	cmdSetupRCU          = cmdSetup.Flag("rcu", "Provisioned read capacity units.").Default("1").Int64()
+	cmdSetupWCU          = cmdSetup.Flag("wcu", "Provisioned write capacity units.").Default("1").Int64()
+	cmdSetupAutoscaleMin = cmdSetup.Flag("autoscale-min", "Enable auto scaling with this minimum capacity, alongside --autoscale-max.").Int32()
+	cmdSetupAutoscaleMax = cmdSetup.Flag("autoscale-max", "Auto scaling maximum capacity, alongside --autoscale-min.").Int32()
+	cmdSetupAutoscaleTgt = cmdSetup.Flag("autoscale-target", "Auto scaling target utilization percentage.").Default("70").Float64()
 
 	cmdGet        = app.Command("get", "Get a credential from the store.")
 	cmdGetName    = cmdGet.Arg("credential", "The name of the credential to get.").Required().String()
@@ -41,15 +56,34 @@ var (
 	cmdPutName    = cmdPut.Arg("credential", "The name of the credential to store.").Required().String()
 	cmdPutSecret  = cmdPut.Arg("value", "The value of the credential to store.").Required().String()
 	cmdPutVersion = cmdPut.Arg("version", "Version to store with the credential.").Int()
+	cmdPutTTL     = cmdPut.Flag("ttl", "Expire the credential after this duration, e.g. 24h.").Duration()
 
 	cmdPutFile           = app.Command("put-file", "Put a credential from a file into the store.")
 	cmdPutFileName       = cmdPutFile.Arg("credential", "The name of the credential to store.").Required().String()
 	cmdPutFileSecretPath = cmdPutFile.Arg("value", "Path to file containing the credential to store.").Required().String()
 	cmdPutFileVersion    = cmdPutFile.Arg("version", "Version to store with the credential.").Int()
+	cmdPutFileTTL        = cmdPutFile.Flag("ttl", "Expire the credential after this duration, e.g. 24h.").Duration()
 
 	cmdDelete     = app.Command("delete", "Delete a credential from the store.")
 	cmdDeleteName = cmdDelete.Arg("credential", "The name of the credential to delete.").Required().String()
 
+	cmdBatchPut     = app.Command("batch-put", "Put many credentials into the store from a JSON file.")
+	cmdBatchPutPath = cmdBatchPut.Arg("file", "Path to a JSON file containing an array of {name, value, version, ttl, alias} entries.").Required().String()
+
+	cmdBatchGet      = app.Command("batch-get", "Get many credentials from the store.")
+	cmdBatchGetNames = cmdBatchGet.Arg("credential", "Names of the credentials to get.").Required().Strings()
+
+	cmdServer              = app.Command("server", "Run a gRPC daemon exposing the credential store as a service.")
+	cmdServerListenNetwork = cmdServer.Flag("listen-network", "Network for the gRPC listener, e.g. unix or tcp.").Default("unix").String()
+	cmdServerListenAddress = cmdServer.Flag("listen-address", "Address for the gRPC listener.").Default("/var/run/unicreds.sock").String()
+	cmdServerHTTPAddr      = cmdServer.Flag("http-addr", "Also serve the REST gateway on this address.").String()
+	cmdServerCacheTTL      = cmdServer.Flag("cache-ttl", "How long to cache decrypted secrets in memory, e.g. 30s.").Duration()
+	cmdServerGrants        = cmdServer.Flag("grants", "Path to the principal->secret-name-prefixes grants file.").Required().String()
+	cmdServerTLSCert       = cmdServer.Flag("tls-cert", "Server TLS certificate, required for mTLS auth.").String()
+	cmdServerTLSKey        = cmdServer.Flag("tls-key", "Server TLS private key, required for mTLS auth.").String()
+	cmdServerTLSClientCA   = cmdServer.Flag("tls-client-ca", "CA bundle used to verify client certificates. Enables mTLS auth; omit to use SigV4 auth instead.").String()
+	cmdServerSTSEndpoint   = cmdServer.Flag("sts-endpoint", "Regional STS endpoint used to verify SigV4 auth, e.g. https://sts.us-east-1.amazonaws.com/.").String()
+
 	// Version app version
 	Version = "1.0.0"
 )
@@ -71,15 +105,34 @@ func main() {
 
 	unicreds.SetRegion(region)
 
+	ctx := context.Background()
+
 	switch command {
 	case cmdSetup.FullCommand():
-		err := unicreds.Setup(dynamoTable)
+		opts := unicreds.SetupOptions{
+			ReadCapacity:  *cmdSetupRCU,
+			WriteCapacity: *cmdSetupWCU,
+		}
+
+		if *cmdSetupBilling == "on-demand" {
+			opts.BillingMode = unicreds.BillingModePayPerRequest
+		}
+
+		if *cmdSetupAutoscaleMin > 0 || *cmdSetupAutoscaleMax > 0 {
+			opts.AutoScaling = &unicreds.AutoScalingPolicy{
+				MinCapacity:       *cmdSetupAutoscaleMin,
+				MaxCapacity:       *cmdSetupAutoscaleMax,
+				TargetUtilization: *cmdSetupAutoscaleTgt,
+			}
+		}
+
+		err := unicreds.Setup(ctx, dynamoTable, opts)
 		if err != nil {
 			printFatalError(err)
 		}
 		log.WithFields(log.Fields{"status": "success"}).Info("Created table")
 	case cmdGet.FullCommand():
-		cred, err := unicreds.GetSecret(dynamoTable, *cmdGetName, *cmdGetVersion)
+		cred, err := unicreds.GetSecret(ctx, dynamoTable, *cmdGetName, *cmdGetVersion)
 		if err != nil {
 			printFatalError(err)
 		}
@@ -92,18 +145,18 @@ func main() {
 		}
 
 	case cmdPut.FullCommand():
-		version, err := unicreds.ResolveVersion(dynamoTable, *cmdPutName, *cmdPutVersion)
+		version, err := unicreds.ResolveVersion(ctx, dynamoTable, *cmdPutName, *cmdPutVersion)
 		if err != nil {
 			printFatalError(err)
 		}
 
-		err = unicreds.PutSecret(dynamoTable, *alias, *cmdPutName, *cmdPutSecret, version)
+		err = unicreds.PutSecret(ctx, dynamoTable, *alias, *cmdPutName, *cmdPutSecret, version, *cmdPutTTL)
 		if err != nil {
 			printFatalError(err)
 		}
 		log.WithFields(log.Fields{"name": *cmdPutName, "version": version}).Info("stored")
 	case cmdPutFile.FullCommand():
-		version, err := unicreds.ResolveVersion(dynamoTable, *cmdPutFileName, *cmdPutFileVersion)
+		version, err := unicreds.ResolveVersion(ctx, dynamoTable, *cmdPutFileName, *cmdPutFileVersion)
 		if err != nil {
 			printFatalError(err)
 		}
@@ -113,13 +166,13 @@ func main() {
 			printFatalError(err)
 		}
 
-		err = unicreds.PutSecret(dynamoTable, *alias, *cmdPutFileName, string(data), version)
+		err = unicreds.PutSecret(ctx, dynamoTable, *alias, *cmdPutFileName, string(data), version, *cmdPutFileTTL)
 		if err != nil {
 			printFatalError(err)
 		}
 		log.WithFields(log.Fields{"name": *cmdPutName, "version": version}).Info("stored")
 	case cmdList.FullCommand():
-		creds, err := unicreds.ListSecrets(dynamoTable, *cmdListAllVersions)
+		creds, err := unicreds.ListSecrets(ctx, dynamoTable, *cmdListAllVersions)
 		if err != nil {
 			printFatalError(err)
 		}
@@ -138,7 +191,7 @@ func main() {
 			printFatalError(err)
 		}
 	case cmdGetAll.FullCommand():
-		creds, err := unicreds.GetAllSecrets(dynamoTable, *cmdGetAllVersions)
+		creds, err := unicreds.GetAllSecrets(ctx, dynamoTable, *cmdGetAllVersions)
 		if err != nil {
 			printFatalError(err)
 		}
@@ -158,11 +211,169 @@ func main() {
 			printFatalError(err)
 		}
 	case cmdDelete.FullCommand():
-		err := unicreds.DeleteSecret(dynamoTable, *cmdDeleteName)
+		err := unicreds.DeleteSecret(ctx, dynamoTable, *cmdDeleteName)
+		if err != nil {
+			printFatalError(err)
+		}
+	case cmdBatchPut.FullCommand():
+		entries, err := readBatchPutFile(*cmdBatchPutPath)
+		if err != nil {
+			printFatalError(err)
+		}
+
+		reqs := make([]unicreds.PutRequest, len(entries))
+		for i, e := range entries {
+			reqs[i] = unicreds.PutRequest{
+				Alias:   e.Alias,
+				Name:    e.Name,
+				Secret:  e.Value,
+				Version: e.Version,
+				TTL:     e.TTL,
+			}
+		}
+
+		if err := unicreds.BatchPutSecrets(ctx, dynamoTable, reqs); err != nil {
+			printFatalError(err)
+		}
+		log.WithFields(log.Fields{"count": len(reqs)}).Info("stored")
+	case cmdBatchGet.FullCommand():
+		creds, err := unicreds.BatchGetSecrets(ctx, dynamoTable, *cmdBatchGetNames)
 		if err != nil {
 			printFatalError(err)
 		}
+
+		table := unicreds.NewTable(os.Stdout)
+		table.SetHeaders([]string{"Name", "Secret"})
+
+		if *csv {
+			table.SetFormat(unicreds.TableFormatCSV)
+		}
+
+		for _, name := range *cmdBatchGetNames {
+			cred, ok := creds[name]
+			if !ok {
+				continue
+			}
+			table.Write([]string{cred.Name, cred.Secret})
+		}
+
+		if err = table.Render(); err != nil {
+			printFatalError(err)
+		}
+	case cmdServer.FullCommand():
+		if err := runServer(ctx); err != nil {
+			printFatalError(err)
+		}
+	}
+}
+
+func runServer(ctx context.Context) error {
+	var opts []func(*config.LoadOptions) error
+	if region != nil && *region != "" {
+		opts = append(opts, config.WithRegion(*region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	grants, err := server.ParseGrants(*cmdServerGrants)
+	if err != nil {
+		return fmt.Errorf("loading grants: %w", err)
+	}
+
+	var auth server.Authenticator
+	var tlsConfig *tls.Config
+
+	if *cmdServerTLSClientCA != "" {
+		cert, err := tls.LoadX509KeyPair(*cmdServerTLSCert, *cmdServerTLSKey)
+		if err != nil {
+			return fmt.Errorf("loading server tls certificate: %w", err)
+		}
+
+		caBundle, err := ioutil.ReadFile(*cmdServerTLSClientCA)
+		if err != nil {
+			return fmt.Errorf("loading client ca bundle: %w", err)
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBundle) {
+			return fmt.Errorf("no certificates found in %s", *cmdServerTLSClientCA)
+		}
+
+		tlsConfig = server.NewServerTLSConfig(cert, clientCAs)
+		auth = &server.MTLSAuthenticator{Grants: grants}
+	} else {
+		endpoint := *cmdServerSTSEndpoint
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com/", cfg.Region)
+		}
+		auth = &server.SigV4Authenticator{STSEndpoint: endpoint, Grants: grants}
+
+		switch {
+		case *cmdServerTLSCert != "" || *cmdServerTLSKey != "":
+			cert, err := tls.LoadX509KeyPair(*cmdServerTLSCert, *cmdServerTLSKey)
+			if err != nil {
+				return fmt.Errorf("loading server tls certificate: %w", err)
+			}
+			tlsConfig = server.NewServerTLSConfigNoClientAuth(cert)
+		case *cmdServerListenNetwork != "unix":
+			// SigV4Authenticator forwards the caller's Authorization/x-amz-*
+			// headers verbatim; without TLS those are replayable by anyone
+			// who can observe the wire. A Unix socket is protected by
+			// filesystem permissions instead, so only refuse to start when
+			// serving over the network in the clear.
+			return fmt.Errorf("server: --tls-cert/--tls-key are required for SigV4 auth over %q listeners", *cmdServerListenNetwork)
+		}
 	}
+
+	srv := server.New(server.Config{
+		Client:    unicreds.New(cfg),
+		TableName: dynamoTable,
+		Auth:      auth,
+		CacheTTL:  *cmdServerCacheTTL,
+	})
+
+	log.WithFields(log.Fields{
+		"network": *cmdServerListenNetwork,
+		"address": *cmdServerListenAddress,
+		"auth":    strings.TrimSuffix(fmt.Sprintf("%T", auth), "Authenticator"),
+	}).Info("starting unicreds server")
+
+	return server.Listen(srv, server.ListenConfig{
+		Network:     *cmdServerListenNetwork,
+		Address:     *cmdServerListenAddress,
+		TLS:         tlsConfig,
+		HTTPAddress: *cmdServerHTTPAddr,
+	})
+}
+
+// batchPutEntry is the JSON shape accepted by the batch-put file argument.
+// TTL is nanoseconds, matching time.Duration's default JSON encoding; zero
+// means the credential never expires.
+type batchPutEntry struct {
+	Name    string        `json:"name"`
+	Value   string        `json:"value"`
+	Version string        `json:"version"`
+	TTL     time.Duration `json:"ttl"`
+	Alias   string        `json:"alias"`
+}
+
+// readBatchPutFile reads and parses the JSON array of entries passed to
+// batch-put.
+func readBatchPutFile(path string) ([]batchPutEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []batchPutEntry
+	if err := encjson.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return entries, nil
 }
 
 func printFatalError(err error) {
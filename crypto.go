@@ -0,0 +1,62 @@
+package unicreds
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// credstashIV is the all-zero counter credstash-compatible clients use to
+// seed AES-CTR. Reusing a counter is only safe because every credential
+// version is encrypted under its own freshly generated data key (see
+// PutSecret/encodePutRequest), so the (key, counter) pair is never repeated.
+var credstashIV = make([]byte, aes.BlockSize)
+
+// Encrypt encrypts plaintext with dataKey (the first 32 bytes of a KMS
+// GenerateDataKey result) using AES-256-CTR, the same envelope scheme
+// credstash uses.
+func Encrypt(dataKey, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCTR(block, credstashIV).XORKeyStream(ciphertext, plaintext)
+
+	return ciphertext, nil
+}
+
+// Decrypt reverses Encrypt. AES-CTR is symmetric, so this just re-runs the
+// keystream XOR.
+func Decrypt(dataKey, ciphertext []byte) ([]byte, error) {
+	return Encrypt(dataKey, ciphertext)
+}
+
+// ComputeHmac256 returns the hex-encoded HMAC-SHA256 of data under hmacKey
+// (the second 32 bytes of a KMS GenerateDataKey result), used to detect
+// tampering with the stored ciphertext.
+func ComputeHmac256(data, hmacKey []byte) string {
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Encode marshals cred into a DynamoDB item keyed by its `ds` struct tags.
+func Encode(cred *Credential) (map[string]types.AttributeValue, error) {
+	return attributevalue.MarshalMapWithOptions(cred, func(o *attributevalue.EncoderOptions) {
+		o.TagKey = "ds"
+	})
+}
+
+// Decode unmarshals a DynamoDB item keyed by `ds` struct tags into cred.
+func Decode(item map[string]types.AttributeValue, cred *Credential) error {
+	return attributevalue.UnmarshalMapWithOptions(item, cred, func(o *attributevalue.DecoderOptions) {
+		o.TagKey = "ds"
+	})
+}
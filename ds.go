@@ -1,6 +1,7 @@
 package unicreds
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"io/ioutil"
@@ -11,11 +12,14 @@ import (
 
 	"github.com/apex/log"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-dax-go-v2/dax"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go"
 )
 
 const (
@@ -27,11 +31,16 @@ const (
 	CreatedAtNotAvailable = "Not Available"
 
 	tableCreateTimeout = 30 * time.Second
+
+	// zoneURL is the EC2 instance metadata endpoint getRegion reads the
+	// availability zone from.
+	zoneURL = "http://169.254.169.254/latest/meta-data/placement/availability-zone"
+
+	// ttlAttribute is the DynamoDB attribute enabled as the table's TTL field
+	ttlAttribute = "expires_at"
 )
 
 var (
-	dynamoSvc dynamodbiface.DynamoDBAPI
-
 	// ErrSecretNotFound returned when unable to find the specified secret in dynamodb
 	ErrSecretNotFound = errors.New("Secret Not Found")
 
@@ -40,15 +49,134 @@ var (
 
 	// ErrTimeout timeout occured waiting for dynamodb table to create
 	ErrTimeout = errors.New("Timed out waiting for dynamodb table to become active")
+
+	// ErrSecretExpired returned when the secret was found but its TTL has passed
+	ErrSecretExpired = errors.New("Secret Expired")
+
+	defaultClient *Client
 )
 
 func init() {
-	dynamoSvc = dynamodb.New(session.New(), aws.NewConfig())
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.WithError(err).Error("unable to load default aws configuration")
+	}
+	defaultClient = New(cfg)
+}
+
+// DynamoDBAPI is the subset of the aws-sdk-go-v2 dynamodb client that
+// unicreds depends on, pulled out so that tests (and things like DAX) can
+// provide their own implementation.
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// KMSAPI is the subset of the aws-sdk-go-v2 kms client that unicreds uses to
+// wrap/unwrap per-credential data keys.
+type KMSAPI interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// Client wraps the dynamodb and kms APIs used to store and retrieve
+// credentials. A default Client built from the ambient AWS configuration is
+// used by the package level functions below, but callers that need custom
+// credentials, mocked APIs (for tests) or a DAX endpoint can construct their
+// own with New and ClientOption.
+//
+// ddb serves reads and may be swapped for a DAX-backed implementation via
+// WithDAX/SetDynamoDBClient to save RCUs on hot reads. writeDdb always talks
+// directly to DynamoDB: writes and strongly consistent reads must bypass the
+// DAX item cache, since a stale highest-version read would produce duplicate
+// PKs (see getHighestVersion). appScaling is only used by Setup, to register
+// auto scaling policies on tables created with provisioned billing.
+type Client struct {
+	ddb        DynamoDBAPI
+	writeDdb   DynamoDBAPI
+	kms        KMSAPI
+	appScaling ApplicationAutoScalingAPI
+}
+
+// ClientOption configures a Client returned by New
+type ClientOption func(*Client)
+
+// WithDAX routes the read path (GetSecret, GetHighestVersion, ListSecrets,
+// GetAllSecrets) through a DAX cluster at endpoint instead of straight to
+// DynamoDB. Writes, and reads that require ConsistentRead, still go straight
+// to DynamoDB since ConsistentRead is incompatible with DAX item-cache hits.
+func WithDAX(endpoint string, cfg aws.Config) ClientOption {
+	return func(c *Client) {
+		// dax.NewConfig merges credentials, region and retry settings from
+		// cfg onto the DAX defaults, so the DAX client resolves the same
+		// AWS identity as the rest of the Client instead of its own
+		// ambient default.
+		daxCfg := dax.NewConfig(cfg, endpoint)
+
+		client, err := dax.New(daxCfg)
+		if err != nil {
+			log.WithError(err).Error("failed to create dax client, falling back to dynamodb")
+			return
+		}
+
+		c.ddb = client
+	}
+}
+
+// New builds a Client from the given aws configuration
+func New(cfg aws.Config, opts ...ClientOption) *Client {
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	c := &Client{
+		ddb:        ddb,
+		writeDdb:   ddb,
+		kms:        kms.NewFromConfig(cfg),
+		appScaling: applicationautoscaling.NewFromConfig(cfg),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
 // SetDynamoDBConfig override the default aws configuration
-func SetDynamoDBConfig(config *aws.Config) {
-	dynamoSvc = dynamodb.New(session.New(), config)
+func SetDynamoDBConfig(cfg aws.Config) {
+	defaultClient = New(cfg)
+}
+
+// SetDynamoDBClient overrides the default Client's read-path DynamoDBAPI
+// implementation, e.g. with a DAX client. Writes continue to go through the
+// Client's raw DynamoDB connection.
+func SetDynamoDBClient(ddb DynamoDBAPI) {
+	defaultClient.ddb = ddb
+}
+
+// SetRegion overrides the default Client's AWS region. A nil or empty
+// region leaves the ambient AWS configuration (env vars, shared config,
+// EC2/ECS/Lambda metadata) in place.
+func SetRegion(region *string) {
+	if region == nil || *region == "" {
+		return
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(*region))
+	if err != nil {
+		log.WithError(err).Error("unable to load aws configuration for region override")
+		return
+	}
+
+	SetDynamoDBConfig(cfg)
 }
 
 // Credential managed credential information
@@ -59,6 +187,12 @@ type Credential struct {
 	Contents  string `ds:"contents"`
 	Hmac      string `ds:"hmac"`
 	CreatedAt int64  `ds:"created_at"`
+	ExpiresAt int64  `ds:"expires_at"`
+}
+
+// Expired reports whether the credential's TTL, if any, has passed
+func (c *Credential) Expired() bool {
+	return c.ExpiresAt != 0 && c.ExpiresAt <= time.Now().Unix()
 }
 
 // CreatedAtDate convert the timestamp field to a date string
@@ -103,76 +237,36 @@ func (slice ByName) Less(i, j int) bool {
 	return slice[i].Name < slice[j].Name
 }
 
-// Setup create the table which stores credentials
-func Setup(tableName *string) (err error) {
-	log.Debug("Running Setup")
-
-	_, err = dynamoSvc.CreateTable(&dynamodb.CreateTableInput{
-		AttributeDefinitions: []*dynamodb.AttributeDefinition{
-			{
-				AttributeName: aws.String("name"),
-				AttributeType: aws.String("S"),
-			},
-			{
-				AttributeName: aws.String("version"),
-				AttributeType: aws.String("S"),
-			},
-		},
-		KeySchema: []*dynamodb.KeySchemaElement{
-			{
-				AttributeName: aws.String("name"),
-				KeyType:       aws.String(dynamodb.KeyTypeHash),
-			},
-			{
-				AttributeName: aws.String("version"),
-				KeyType:       aws.String(dynamodb.KeyTypeRange),
-			},
-		},
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(1),
-			WriteCapacityUnits: aws.Int64(1),
-		},
-		TableName: tableName,
-	})
-
-	if err != nil {
-		return
-	}
-
-	err = waitForTable(tableName)
-
-	return
+// GetSecret retrieve the secret from dynamodb using the name and optionally version
+func GetSecret(ctx context.Context, tableName *string, name, version string) (*DecryptedCredential, error) {
+	return defaultClient.GetSecret(ctx, tableName, name, version)
 }
 
 // GetSecret retrieve the secret from dynamodb using the name and optionally version
-func GetSecret(tableName *string, name, version string) (*DecryptedCredential, error) {
+func (c *Client) GetSecret(ctx context.Context, tableName *string, name, version string) (*DecryptedCredential, error) {
 	log.Debug("Getting secret")
 
-	exprAttrVal := map[string]*dynamodb.AttributeValue{
-		":name": &dynamodb.AttributeValue{
-			S: aws.String(name),
-		},
+	exprAttrVal := map[string]types.AttributeValue{
+		":name": &types.AttributeValueMemberS{Value: name},
 	}
 	var keyCondExpr *string
 	if len(version) > 0 {
-		exprAttrVal[":version"] = &dynamodb.AttributeValue{
-			S: aws.String(version),
-		}
+		exprAttrVal[":version"] = &types.AttributeValueMemberS{Value: version}
 		keyCondExpr = aws.String("#N = :name AND version = :version")
 	} else {
 		keyCondExpr = aws.String("#N = :name")
 	}
 
-	res, err := dynamoSvc.Query(&dynamodb.QueryInput{
+	res, err := c.ddb.Query(ctx, &dynamodb.QueryInput{
 		TableName: tableName,
-		ExpressionAttributeNames: map[string]*string{
-			"#N": aws.String("name"),
+		ExpressionAttributeNames: map[string]string{
+			"#N": "name",
 		},
 		ExpressionAttributeValues: exprAttrVal,
 		KeyConditionExpression:    keyCondExpr,
-		Limit:            aws.Int64(1),
-		ConsistentRead:   aws.Bool(true),
-		ScanIndexForward: aws.Bool(false), // descending order
+		Limit:                     aws.Int32(1),
+		ConsistentRead:            aws.Bool(true),
+		ScanIndexForward:          aws.Bool(false), // descending order
 	})
 
 	if err != nil {
@@ -191,57 +285,96 @@ func GetSecret(tableName *string, name, version string) (*DecryptedCredential, e
 		return nil, err
 	}
 
-	return decryptCredential(cred)
+	if cred.Expired() {
+		return nil, ErrSecretExpired
+	}
+
+	return c.decryptCredential(ctx, cred)
+}
+
+// GetHighestVersion look up the highest version for a given name
+func GetHighestVersion(ctx context.Context, tableName *string, name string) (string, error) {
+	return defaultClient.GetHighestVersion(ctx, tableName, name)
 }
 
 // GetHighestVersion look up the highest version for a given name
-func GetHighestVersion(tableName *string, name string) (string, error) {
+func (c *Client) GetHighestVersion(ctx context.Context, tableName *string, name string) (string, error) {
+	version, expired, err := c.getHighestVersion(ctx, c.ddb, tableName, name)
+	if err != nil {
+		return "", err
+	}
+	if expired {
+		return "", ErrSecretExpired
+	}
+	return version, nil
+}
+
+// getHighestVersion is split out so that callers which cannot tolerate a
+// stale DAX item-cache read (ResolveVersion, which would otherwise mint a
+// duplicate PK) can force the lookup straight to DynamoDB. It reports
+// expiry separately from error so ResolveVersion, which needs the real
+// highest version number regardless of TTL, can ignore it.
+func (c *Client) getHighestVersion(ctx context.Context, ddb DynamoDBAPI, tableName *string, name string) (version string, expired bool, err error) {
 	log.WithField("name", name).Debug("Looking up highest version")
 
-	res, err := dynamoSvc.Query(&dynamodb.QueryInput{
+	res, err := ddb.Query(ctx, &dynamodb.QueryInput{
 		TableName: tableName,
-		ExpressionAttributeNames: map[string]*string{
-			"#N": aws.String("name"),
+		ExpressionAttributeNames: map[string]string{
+			"#N": "name",
 		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":name": &dynamodb.AttributeValue{
-				S: aws.String(name),
-			},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":name": &types.AttributeValueMemberS{Value: name},
 		},
 		KeyConditionExpression: aws.String("#N = :name"),
-		Limit:                aws.Int64(1),
-		ConsistentRead:       aws.Bool(true),
-		ScanIndexForward:     aws.Bool(false), // descending order
-		ProjectionExpression: aws.String("version"),
+		Limit:                  aws.Int32(1),
+		ConsistentRead:         aws.Bool(true),
+		ScanIndexForward:       aws.Bool(false), // descending order
+		ProjectionExpression:   aws.String("version, expires_at"),
 	})
 
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	if len(res.Items) == 0 {
-		return "", ErrSecretNotFound
+		return "", false, ErrSecretNotFound
 	}
 
 	v := res.Items[0]["version"]
 
 	if v == nil {
-		return "", ErrSecretNotFound
+		return "", false, ErrSecretNotFound
+	}
+
+	sv, ok := v.(*types.AttributeValueMemberS)
+	if !ok {
+		return "", false, ErrSecretNotFound
+	}
+
+	if ea, ok := res.Items[0][ttlAttribute].(*types.AttributeValueMemberN); ok {
+		if expiresAt, err := strconv.ParseInt(ea.Value, 10, 64); err == nil {
+			expired = expiresAt != 0 && expiresAt <= time.Now().Unix()
+		}
 	}
 
-	return aws.StringValue(v.S), nil
+	return sv.Value, expired, nil
 }
 
 // ListSecrets returns a list of all secrets
-func ListSecrets(tableName *string, allVersions bool) ([]*Credential, error) {
+func ListSecrets(ctx context.Context, tableName *string, allVersions bool) ([]*Credential, error) {
+	return defaultClient.ListSecrets(ctx, tableName, allVersions)
+}
+
+// ListSecrets returns a list of all secrets
+func (c *Client) ListSecrets(ctx context.Context, tableName *string, allVersions bool) ([]*Credential, error) {
 	log.Debug("Listing secrets")
 
-	res, err := dynamoSvc.Scan(&dynamodb.ScanInput{
+	res, err := c.ddb.Scan(ctx, &dynamodb.ScanInput{
 		TableName: tableName,
-		ExpressionAttributeNames: map[string]*string{
-			"#N": aws.String("name"),
+		ExpressionAttributeNames: map[string]string{
+			"#N": "name",
 		},
-		ProjectionExpression: aws.String("#N, version, created_at"),
+		ProjectionExpression: aws.String("#N, version, created_at, expires_at"),
 		ConsistentRead:       aws.Bool(true),
 	})
 	if err != nil {
@@ -253,6 +386,8 @@ func ListSecrets(tableName *string, allVersions bool) ([]*Credential, error) {
 		return nil, err
 	}
 
+	creds = filterExpired(creds)
+
 	if !allVersions {
 		creds, err = filterLatest(creds)
 		if err != nil {
@@ -266,18 +401,24 @@ func ListSecrets(tableName *string, allVersions bool) ([]*Credential, error) {
 }
 
 // GetAllSecrets returns a list of all secrets
-func GetAllSecrets(tableName *string, allVersions bool) ([]*DecryptedCredential, error) {
+func GetAllSecrets(ctx context.Context, tableName *string, allVersions bool) ([]*DecryptedCredential, error) {
+	return defaultClient.GetAllSecrets(ctx, tableName, allVersions)
+}
+
+// GetAllSecrets returns a list of all secrets
+func (c *Client) GetAllSecrets(ctx context.Context, tableName *string, allVersions bool) ([]*DecryptedCredential, error) {
 	log.Debug("Getting all secrets")
 
-	res, err := dynamoSvc.Scan(&dynamodb.ScanInput{
+	res, err := c.ddb.Scan(ctx, &dynamodb.ScanInput{
 		TableName: tableName,
-		AttributesToGet: []*string{
-			aws.String("name"),
-			aws.String("version"),
-			aws.String("key"),
-			aws.String("contents"),
-			aws.String("hmac"),
-			aws.String("created_at"),
+		AttributesToGet: []string{
+			"name",
+			"version",
+			"key",
+			"contents",
+			"hmac",
+			"created_at",
+			"expires_at",
 		},
 		ConsistentRead: aws.Bool(true),
 	})
@@ -290,6 +431,8 @@ func GetAllSecrets(tableName *string, allVersions bool) ([]*DecryptedCredential,
 		return nil, err
 	}
 
+	creds = filterExpired(creds)
+
 	if !allVersions {
 		creds, err = filterLatest(creds)
 		if err != nil {
@@ -303,13 +446,12 @@ func GetAllSecrets(tableName *string, allVersions bool) ([]*DecryptedCredential,
 
 	for _, cred := range creds {
 
-		dcred, err := decryptCredential(cred)
+		dcred, err := c.decryptCredential(ctx, cred)
 		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok {
-				if awsErr.Code() == "AccessDeniedException" {
-					log.Debugf("KMS Access Denied to decrypt: %s", cred.Name)
-					continue
-				}
+			var apiErr smithy.APIError
+			if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException" {
+				log.Debugf("KMS Access Denied to decrypt: %s", cred.Name)
+				continue
 			}
 		}
 
@@ -319,8 +461,15 @@ func GetAllSecrets(tableName *string, allVersions bool) ([]*DecryptedCredential,
 	return results, nil
 }
 
-// PutSecret retrieve the secret from dynamodb
-func PutSecret(tableName *string, alias, name, secret, version string) error {
+// PutSecret retrieve the secret from dynamodb. A ttl of 0 means the secret
+// never expires.
+func PutSecret(ctx context.Context, tableName *string, alias, name, secret, version string, ttl time.Duration) error {
+	return defaultClient.PutSecret(ctx, tableName, alias, name, secret, version, ttl)
+}
+
+// PutSecret retrieve the secret from dynamodb. A ttl of 0 means the secret
+// never expires.
+func (c *Client) PutSecret(ctx context.Context, tableName *string, alias, name, secret, version string, ttl time.Duration) error {
 	log.Debug("Putting secret")
 
 	kmsKey := DefaultKmsKey
@@ -333,7 +482,7 @@ func PutSecret(tableName *string, alias, name, secret, version string) error {
 		version = "1"
 	}
 
-	dk, err := GenerateDataKey(kmsKey, 64)
+	dk, err := c.GenerateDataKey(ctx, kmsKey, 64)
 	if err != nil {
 		log.Debugf("GenerateDataKey failed: %v", err)
 		return err
@@ -353,6 +502,11 @@ func PutSecret(tableName *string, alias, name, secret, version string) error {
 
 	b64ctext := base64.StdEncoding.EncodeToString(ctext)
 
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).Unix()
+	}
+
 	cred := &Credential{
 		Name:      name,
 		Version:   version,
@@ -360,6 +514,7 @@ func PutSecret(tableName *string, alias, name, secret, version string) error {
 		Contents:  b64ctext,
 		Hmac:      b64hmac,
 		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiresAt,
 	}
 
 	data, err := Encode(cred)
@@ -369,11 +524,11 @@ func PutSecret(tableName *string, alias, name, secret, version string) error {
 		return err
 	}
 
-	_, err = dynamoSvc.PutItem(&dynamodb.PutItemInput{
+	_, err = c.writeDdb.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName: tableName,
 		Item:      data,
-		ExpressionAttributeNames: map[string]*string{
-			"#N": aws.String("name"),
+		ExpressionAttributeNames: map[string]string{
+			"#N": "name",
 		},
 		ConditionExpression: aws.String("attribute_not_exists(#N)"),
 	})
@@ -382,18 +537,21 @@ func PutSecret(tableName *string, alias, name, secret, version string) error {
 }
 
 // DeleteSecret delete a secret
-func DeleteSecret(tableName *string, name string) error {
+func DeleteSecret(ctx context.Context, tableName *string, name string) error {
+	return defaultClient.DeleteSecret(ctx, tableName, name)
+}
+
+// DeleteSecret delete a secret
+func (c *Client) DeleteSecret(ctx context.Context, tableName *string, name string) error {
 	log.Debug("Deleting secret")
 
-	res, err := dynamoSvc.Query(&dynamodb.QueryInput{
+	res, err := c.writeDdb.Query(ctx, &dynamodb.QueryInput{
 		TableName: tableName,
-		ExpressionAttributeNames: map[string]*string{
-			"#N": aws.String("name"),
+		ExpressionAttributeNames: map[string]string{
+			"#N": "name",
 		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":name": &dynamodb.AttributeValue{
-				S: aws.String(name),
-			},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":name": &types.AttributeValueMemberS{Value: name},
 		},
 		KeyConditionExpression: aws.String("#N = :name"),
 		ConsistentRead:         aws.Bool(true),
@@ -414,15 +572,11 @@ func DeleteSecret(tableName *string, name string) error {
 
 		log.WithFields(log.Fields{"name": cred.Name, "version": cred.Version}).Info("deleting")
 
-		_, err = dynamoSvc.DeleteItem(&dynamodb.DeleteItemInput{
+		_, err = c.writeDdb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 			TableName: tableName,
-			Key: map[string]*dynamodb.AttributeValue{
-				"name": &dynamodb.AttributeValue{
-					S: aws.String(cred.Name),
-				},
-				"version": &dynamodb.AttributeValue{
-					S: aws.String(cred.Version),
-				},
+			Key: map[string]types.AttributeValue{
+				"name":    &types.AttributeValueMemberS{Value: cred.Name},
+				"version": &types.AttributeValueMemberS{Value: cred.Version},
 			},
 		})
 
@@ -435,14 +589,23 @@ func DeleteSecret(tableName *string, name string) error {
 }
 
 // ResolveVersion calculate the version given a name and version
-func ResolveVersion(tableName *string, name string, version int) (string, error) {
+func ResolveVersion(ctx context.Context, tableName *string, name string, version int) (string, error) {
+	return defaultClient.ResolveVersion(ctx, tableName, name, version)
+}
+
+// ResolveVersion calculate the version given a name and version
+func (c *Client) ResolveVersion(ctx context.Context, tableName *string, name string, version int) (string, error) {
 	log.Debug("Resolving version")
 
 	if version != 0 {
 		return strconv.Itoa(version), nil
 	}
 
-	ver, err := GetHighestVersion(tableName, name)
+	// Bypass the configured (possibly DAX) read path: a stale highest-version
+	// read here would silently overwrite an existing version with the same PK.
+	// The numbering must continue from the real highest version regardless of
+	// TTL expiry, so the expired flag is ignored here.
+	ver, _, err := c.getHighestVersion(ctx, c.writeDdb, tableName, name)
 	if err != nil {
 		if err == ErrSecretNotFound {
 			return "1", nil
@@ -459,7 +622,32 @@ func ResolveVersion(tableName *string, name string, version int) (string, error)
 	return strconv.Itoa(version), nil
 }
 
-func decryptCredential(cred *Credential) (*DecryptedCredential, error) {
+// GenerateDataKey asks KMS to mint a new data encryption key wrapped by the
+// given key alias/id
+func GenerateDataKey(ctx context.Context, alias string, numBytes int32) (*kms.GenerateDataKeyOutput, error) {
+	return defaultClient.GenerateDataKey(ctx, alias, numBytes)
+}
+
+// GenerateDataKey asks KMS to mint a new data encryption key wrapped by the
+// given key alias/id
+func (c *Client) GenerateDataKey(ctx context.Context, alias string, numBytes int32) (*kms.GenerateDataKeyOutput, error) {
+	return c.kms.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:         aws.String(alias),
+		NumberOfBytes: aws.Int32(numBytes),
+	})
+}
+
+// DecryptDataKey asks KMS to unwrap a previously generated data key
+func DecryptDataKey(ctx context.Context, wrappedKey []byte) (*kms.DecryptOutput, error) {
+	return defaultClient.DecryptDataKey(ctx, wrappedKey)
+}
+
+// DecryptDataKey asks KMS to unwrap a previously generated data key
+func (c *Client) DecryptDataKey(ctx context.Context, wrappedKey []byte) (*kms.DecryptOutput, error) {
+	return c.kms.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: wrappedKey})
+}
+
+func (c *Client) decryptCredential(ctx context.Context, cred *Credential) (*DecryptedCredential, error) {
 
 	wrappedKey, err := base64.StdEncoding.DecodeString(cred.Key)
 
@@ -467,7 +655,7 @@ func decryptCredential(cred *Credential) (*DecryptedCredential, error) {
 		return nil, err
 	}
 
-	dk, err := DecryptDataKey(wrappedKey)
+	dk, err := c.DecryptDataKey(ctx, wrappedKey)
 
 	if err != nil {
 		return nil, err
@@ -498,7 +686,7 @@ func decryptCredential(cred *Credential) (*DecryptedCredential, error) {
 	return &DecryptedCredential{Credential: cred, Secret: plainText}, nil
 }
 
-func decodeCredential(items []map[string]*dynamodb.AttributeValue) ([]*Credential, error) {
+func decodeCredential(items []map[string]types.AttributeValue) ([]*Credential, error) {
 
 	results := make([]*Credential, 0, len(items))
 
@@ -515,6 +703,19 @@ func decodeCredential(items []map[string]*dynamodb.AttributeValue) ([]*Credentia
 	return results, nil
 }
 
+// filterExpired drops credentials whose TTL has passed. DynamoDB's TTL
+// sweeper can lag up to 48h behind the expires_at attribute, so reads can't
+// rely on it to have removed the item yet.
+func filterExpired(creds []*Credential) []*Credential {
+	results := make([]*Credential, 0, len(creds))
+	for _, cred := range creds {
+		if !cred.Expired() {
+			results = append(results, cred)
+		}
+	}
+	return results
+}
+
 func filterLatest(creds []*Credential) ([]*Credential, error) {
 
 	sort.Sort(ByVersion(creds))
@@ -537,42 +738,6 @@ func filterLatest(creds []*Credential) ([]*Credential, error) {
 	return results, nil
 }
 
-func waitForTable(tableName *string) error {
-
-	timeout := make(chan bool, 1)
-	go func() {
-		time.Sleep(tableCreateTimeout)
-		timeout <- true
-	}()
-
-	ticker := time.NewTicker(1 * time.Second)
-
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			// a read from ch has occurred
-			res, err := dynamoSvc.DescribeTable(&dynamodb.DescribeTableInput{
-				TableName: tableName,
-			})
-
-			if err != nil {
-				return err
-			}
-
-			if *res.Table.TableStatus == "ACTIVE" {
-				return nil
-			}
-
-		case <-timeout:
-			// polling for table status has taken more than the timeout
-			return ErrTimeout
-		}
-	}
-
-}
-
 func getRegion() (*string, error) {
 	// Use meta-data to get our region
 	timeout := time.Duration(5 * time.Second)
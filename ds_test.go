@@ -0,0 +1,139 @@
+package unicreds
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// mockDynamoDB implements DynamoDBAPI, panicking on any method a test
+// didn't stub out.
+type mockDynamoDB struct {
+	DynamoDBAPI
+
+	queryFn   func(ctx context.Context, params *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	putItemFn func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+}
+
+func (m *mockDynamoDB) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return m.queryFn(ctx, params)
+}
+
+func (m *mockDynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return m.putItemFn(ctx, params)
+}
+
+// mockKMS implements KMSAPI, panicking on any method a test didn't stub
+// out.
+type mockKMS struct {
+	KMSAPI
+
+	generateDataKeyFn func(ctx context.Context, params *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error)
+	decryptFn         func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error)
+}
+
+func (m *mockKMS) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	return m.generateDataKeyFn(ctx, params)
+}
+
+func (m *mockKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	return m.decryptFn(ctx, params)
+}
+
+func TestClientGetSecretNotFound(t *testing.T) {
+	c := &Client{
+		ddb: &mockDynamoDB{
+			queryFn: func(ctx context.Context, params *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+				return &dynamodb.QueryOutput{}, nil
+			},
+		},
+	}
+
+	_, err := c.GetSecret(context.Background(), stringPtr("table"), "missing", "")
+	if err != ErrSecretNotFound {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestClientGetSecretDecrypts(t *testing.T) {
+	dataKey := make([]byte, 32)
+	hmacKey := make([]byte, 32)
+	plaintext := []byte("s3cr3t")
+
+	ctext, err := Encrypt(dataKey, plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	cred := &Credential{
+		Name:     "foo",
+		Version:  "1",
+		Key:      "d2VpcmRrZXk=",
+		Contents: base64.StdEncoding.EncodeToString(ctext),
+		Hmac:     ComputeHmac256(ctext, hmacKey),
+	}
+
+	item, err := Encode(cred)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	c := &Client{
+		ddb: &mockDynamoDB{
+			queryFn: func(ctx context.Context, params *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+				return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{item}}, nil
+			},
+		},
+		kms: &mockKMS{
+			decryptFn: func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+				return &kms.DecryptOutput{Plaintext: append(append([]byte{}, dataKey...), hmacKey...)}, nil
+			},
+		},
+	}
+
+	got, err := c.GetSecret(context.Background(), stringPtr("table"), "foo", "")
+	if err != nil {
+		t.Fatalf("GetSecret: %v", err)
+	}
+	if got.Secret != string(plaintext) {
+		t.Fatalf("expected secret %q, got %q", plaintext, got.Secret)
+	}
+}
+
+func TestClientPutSecretConditionsOnNotExists(t *testing.T) {
+	var gotInput *dynamodb.PutItemInput
+
+	c := &Client{
+		writeDdb: &mockDynamoDB{
+			putItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+				gotInput = params
+				return &dynamodb.PutItemOutput{}, nil
+			},
+		},
+		kms: &mockKMS{
+			generateDataKeyFn: func(ctx context.Context, params *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error) {
+				return &kms.GenerateDataKeyOutput{
+					Plaintext:      make([]byte, 64),
+					CiphertextBlob: []byte("wrapped"),
+				}, nil
+			},
+		},
+	}
+
+	if err := c.PutSecret(context.Background(), stringPtr("table"), "", "foo", "s3cr3t", "", 0); err != nil {
+		t.Fatalf("PutSecret: %v", err)
+	}
+
+	if gotInput == nil {
+		t.Fatal("expected PutItem to be called")
+	}
+	if gotInput.ConditionExpression == nil || *gotInput.ConditionExpression != "attribute_not_exists(#N)" {
+		t.Fatalf("expected a ConditionExpression guarding against overwrite, got %v", gotInput.ConditionExpression)
+	}
+}
+
+func stringPtr(s string) *string { return &s }
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Authenticator maps an authenticated caller to the secret-name prefixes it
+// is allowed to touch. It returns an error if the caller could not be
+// authenticated at all.
+type Authenticator interface {
+	Authorize(ctx context.Context) (allowedPrefixes []string, err error)
+}
+
+// authorized reports whether name is covered by one of allowedPrefixes.
+func authorized(allowedPrefixes []string, name string) bool {
+	for _, prefix := range allowedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// MTLSAuthenticator authorizes callers by the CommonName on the client
+// certificate presented during the TLS handshake, looked up against a static
+// CN -> allowed secret-name-prefixes map.
+type MTLSAuthenticator struct {
+	// Grants maps a client certificate CommonName to the secret-name
+	// prefixes that CN is allowed to Get/Put/Delete/Rotate.
+	Grants map[string][]string
+}
+
+// Authorize implements Authenticator using the peer's verified client
+// certificate CommonName.
+func (a *MTLSAuthenticator) Authorize(ctx context.Context) ([]string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("server: no peer information on request")
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("server: no client certificate presented")
+	}
+
+	cn := tlsInfo.State.PeerCertificates[0].Subject.CommonName
+
+	prefixes, ok := a.Grants[cn]
+	if !ok {
+		return nil, fmt.Errorf("server: no grants configured for client certificate CN %q", cn)
+	}
+
+	return prefixes, nil
+}
+
+// NewServerTLSConfig builds the server-side tls.Config for mTLS mode: it
+// terminates TLS with cert and requires a client certificate signed by one
+// of clientCAs, which MTLSAuthenticator then reads the CN from.
+func NewServerTLSConfig(cert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+}
+
+// NewServerTLSConfigNoClientAuth builds the server-side tls.Config for
+// SigV4 mode: it terminates TLS with cert but does not request or verify a
+// client certificate, since SigV4Authenticator authorizes callers from their
+// forwarded SigV4 headers rather than a certificate. Those headers are
+// effectively a short-lived bearer credential, so TLS is required here to
+// keep them from being replayed by anyone who can observe the wire.
+func NewServerTLSConfigNoClientAuth(cert tls.Certificate) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+}
+
+// sigV4ForwardedHeaders are the gRPC metadata keys a SigV4-authenticating
+// client must set, copied verbatim from the SigV4-signed
+// "sts:GetCallerIdentity" request it built for itself.
+var sigV4ForwardedHeaders = []string{"authorization", "x-amz-date", "x-amz-security-token", "host"}
+
+// SigV4Authenticator authorizes callers by replaying their SigV4-signed
+// GetCallerIdentity request headers against STS (the same trick used by
+// Vault's and Kubernetes' IAM auth methods) and mapping the ARN STS hands
+// back to an allowed set of secret-name prefixes. This avoids unicreds
+// needing its own SigV4 verifier or the caller's credentials.
+type SigV4Authenticator struct {
+	// STSEndpoint is the regional STS endpoint to replay requests against,
+	// e.g. "https://sts.us-east-1.amazonaws.com/".
+	STSEndpoint string
+
+	// HTTPClient defaults to http.DefaultClient when nil.
+	HTTPClient *http.Client
+
+	// Grants maps a caller ARN to the secret-name prefixes it is allowed to
+	// Get/Put/Delete/Rotate.
+	Grants map[string][]string
+}
+
+type stsCallerIdentityResponse struct {
+	XMLName xml.Name `xml:"GetCallerIdentityResponse"`
+	Result  struct {
+		Arn string `xml:"Arn"`
+	} `xml:"GetCallerIdentityResult"`
+}
+
+// Authorize implements Authenticator by forwarding the caller's signed
+// headers to STS and trusting the ARN it resolves the signature to.
+func (a *SigV4Authenticator) Authorize(ctx context.Context) ([]string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("server: no request metadata to authenticate")
+	}
+
+	if v := md.Get("authorization"); len(v) == 0 || v[0] == "" {
+		return nil, fmt.Errorf("server: missing SigV4 authorization metadata")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.STSEndpoint, strings.NewReader("Action=GetCallerIdentity&Version=2011-06-15"))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	for _, key := range sigV4ForwardedHeaders {
+		if v := md.Get(key); len(v) > 0 && v[0] != "" {
+			req.Header.Set(key, v[0])
+		}
+	}
+
+	client := a.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("server: failed to verify caller identity: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server: sts rejected caller identity: %s", body)
+	}
+
+	var parsed stsCallerIdentityResponse
+	if err := xml.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("server: failed to parse sts response: %w", err)
+	}
+
+	arn := canonicalizeARN(parsed.Result.Arn)
+
+	prefixes, ok := a.Grants[arn]
+	if !ok {
+		return nil, fmt.Errorf("server: no grants configured for principal %q", arn)
+	}
+
+	return prefixes, nil
+}
+
+// canonicalizeARN rewrites an STS assumed-role ARN, which embeds a
+// caller-chosen session name
+// ("arn:aws:sts::ACCOUNT:assumed-role/ROLE/SESSION-NAME"), down to the
+// underlying IAM role ARN ("arn:aws:iam::ACCOUNT:role/ROLE") so it matches a
+// static grants file regardless of session name. This is the same
+// canonicalization Vault's AWS IAM auth method applies. ARNs that are not an
+// assumed-role (e.g. an IAM user) are returned unchanged.
+func canonicalizeARN(arn string) string {
+	const prefix = ":sts::"
+	const marker = ":assumed-role/"
+
+	i := strings.Index(arn, prefix)
+	j := strings.Index(arn, marker)
+	if i < 0 || j < 0 || j < i {
+		return arn
+	}
+
+	account := arn[i+len(prefix) : j]
+
+	rest := arn[j+len(marker):]
+	role, _, ok := strings.Cut(rest, "/")
+	if !ok {
+		return arn
+	}
+
+	return fmt.Sprintf("arn:aws:iam::%s:role/%s", account, role)
+}
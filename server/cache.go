@@ -0,0 +1,137 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MasteryConnect/unicreds"
+)
+
+// plaintextCache holds decrypted secret values (and the credential metadata
+// they were resolved from) in memory for ttl so that repeated Get RPCs for
+// the same name/version don't re-invoke KMS on every call. It is unrelated
+// to the DynamoDB-side expires_at TTL feature: entries here expire from the
+// daemon's memory, not from the credential store.
+type plaintextCache struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	// entries is keyed by cacheKey(name, requestedVersion).
+	entries map[string]*cacheEntry
+	// byName indexes the cacheKeys cached for a given name, so evictName
+	// can drop every cached version of a name in one call without knowing
+	// which versions were ever requested.
+	byName map[string]map[string]struct{}
+}
+
+type cacheEntry struct {
+	cred    *unicreds.Credential
+	secret  []byte
+	expires time.Time
+}
+
+// newPlaintextCache builds a cache that evicts entries ttl after they were
+// stored. A ttl of 0 disables caching: get always misses and put is a no-op.
+func newPlaintextCache(ttl time.Duration) *plaintextCache {
+	return &plaintextCache{
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+		byName:  make(map[string]map[string]struct{}),
+	}
+}
+
+// get returns the cached credential metadata and a copy of the plaintext
+// for name/key, or (nil, "", false) on a miss or expiry.
+func (c *plaintextCache) get(name, key string) (*unicreds.Credential, string, bool) {
+	if c.ttl <= 0 {
+		return nil, "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, "", false
+	}
+
+	if time.Now().After(entry.expires) {
+		c.deleteLocked(name, key)
+		return nil, "", false
+	}
+
+	return entry.cred, string(entry.secret), true
+}
+
+// put stores a copy of secret, and cred, under name/key, overwriting any
+// existing entry.
+func (c *plaintextCache) put(name, key string, cred *unicreds.Credential, secret string) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.entries[key]; ok {
+		zero(existing.secret)
+	}
+
+	c.entries[key] = &cacheEntry{
+		cred:    cred,
+		secret:  []byte(secret),
+		expires: time.Now().Add(c.ttl),
+	}
+
+	if c.byName[name] == nil {
+		c.byName[name] = make(map[string]struct{})
+	}
+	c.byName[name][key] = struct{}{}
+}
+
+// evict removes name/key from the cache, zeroing its buffer first. Used to
+// drop a single cached plaintext immediately after a Put/Rotate invalidates
+// just that version.
+func (c *plaintextCache) evict(name, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deleteLocked(name, key)
+}
+
+// evictName removes every cached version of name, zeroing each buffer
+// first. Used after a Delete, which removes every version of name from
+// DynamoDB -- leaving any version-specific cache entry behind would keep
+// serving deleted plaintext until it expires on its own.
+func (c *plaintextCache) evictName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.byName[name] {
+		c.deleteLocked(name, key)
+	}
+}
+
+// deleteLocked removes name/key from entries and the byName index. Callers
+// must hold c.mu.
+func (c *plaintextCache) deleteLocked(name, key string) {
+	if entry, ok := c.entries[key]; ok {
+		zero(entry.secret)
+		delete(c.entries, key)
+	}
+
+	delete(c.byName[name], key)
+	if len(c.byName[name]) == 0 {
+		delete(c.byName, name)
+	}
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+func cacheKey(name, version string) string {
+	return name + "\x00" + version
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/MasteryConnect/unicreds/server/pb"
+)
+
+// gateway is a minimal REST front-end over Server, for callers that would
+// rather issue a plain HTTP request than link a gRPC client. It is a
+// hand-rolled stand-in for a generated grpc-gateway mux: one JSON endpoint
+// per RPC rather than the full grpc-gateway annotation/codegen pipeline.
+type gateway struct {
+	srv *Server
+	mux *http.ServeMux
+
+	// tlsConfig, when set, is used to terminate TLS on the gateway's own
+	// listener so MTLSAuthenticator sees the same client certificate a
+	// gRPC caller would present.
+	tlsConfig *tls.Config
+}
+
+func newGateway(srv *Server, tlsConfig *tls.Config) *gateway {
+	g := &gateway{srv: srv, mux: http.NewServeMux(), tlsConfig: tlsConfig}
+	g.mux.HandleFunc("/v1/secrets/get", g.handle(func() interface{} { return new(pb.GetRequest) }, func(r *http.Request, req interface{}) (interface{}, error) {
+		return g.srv.Get(r.Context(), req.(*pb.GetRequest))
+	}))
+	g.mux.HandleFunc("/v1/secrets/put", g.handle(func() interface{} { return new(pb.PutRequest) }, func(r *http.Request, req interface{}) (interface{}, error) {
+		return g.srv.Put(r.Context(), req.(*pb.PutRequest))
+	}))
+	g.mux.HandleFunc("/v1/secrets/list", g.handle(func() interface{} { return new(pb.ListRequest) }, func(r *http.Request, req interface{}) (interface{}, error) {
+		return g.srv.List(r.Context(), req.(*pb.ListRequest))
+	}))
+	g.mux.HandleFunc("/v1/secrets/delete", g.handle(func() interface{} { return new(pb.DeleteRequest) }, func(r *http.Request, req interface{}) (interface{}, error) {
+		return g.srv.Delete(r.Context(), req.(*pb.DeleteRequest))
+	}))
+	g.mux.HandleFunc("/v1/secrets/rotate", g.handle(func() interface{} { return new(pb.RotateRequest) }, func(r *http.Request, req interface{}) (interface{}, error) {
+		return g.srv.Rotate(r.Context(), req.(*pb.RotateRequest))
+	}))
+	return g
+}
+
+// handle decodes the request body into a fresh message from newReq, invokes
+// call, and writes the result (or error) back as JSON.
+func (g *gateway) handle(newReq func() interface{}, call func(*http.Request, interface{}) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		req := newReq()
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		r = r.WithContext(contextFromRequest(r))
+
+		res, err := call(r, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// contextFromRequest builds a context carrying the same peer/metadata
+// information a gRPC call would, so an Authenticator written against
+// peer.FromContext/metadata.FromIncomingContext works unchanged whether the
+// caller came in over gRPC or this REST gateway.
+func contextFromRequest(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for k, v := range r.Header {
+		md.Append(k, v...)
+	}
+
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	if r.TLS != nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{
+			Addr:     addr(r.RemoteAddr),
+			AuthInfo: credentials.TLSInfo{State: *r.TLS},
+		})
+	}
+
+	return ctx
+}
+
+// addr adapts a net/http RemoteAddr string to net.Addr for peer.Peer, which
+// only ever reads its String() form back out.
+type addr string
+
+func (a addr) Network() string { return "tcp" }
+func (a addr) String() string  { return string(a) }
+
+// ListenAndServe blocks serving the REST gateway on addr. If the gateway was
+// configured with a TLS config, it terminates TLS itself so
+// MTLSAuthenticator can read the client certificate straight off the
+// request, the same way it would off a gRPC peer.
+func (g *gateway) ListenAndServe(address string) error {
+	srv := &http.Server{Addr: address, Handler: g.mux, TLSConfig: g.tlsConfig}
+
+	if g.tlsConfig != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseGrants reads a grants file mapping a principal (a client certificate
+// CommonName for MTLSAuthenticator, or an IAM ARN for SigV4Authenticator) to
+// the secret-name prefixes it may access, one per line:
+//
+//	principal = prefix1,prefix2
+//
+// Blank lines and lines starting with '#' are ignored.
+func ParseGrants(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	grants := make(map[string][]string)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		principal, prefixes, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("server: malformed grants line %q", line)
+		}
+
+		var list []string
+		for _, p := range strings.Split(prefixes, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				list = append(list, p)
+			}
+		}
+
+		grants[strings.TrimSpace(principal)] = list
+	}
+
+	return grants, scanner.Err()
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/apex/log"
+	"google.golang.org/grpc"
+	grpccredentials "google.golang.org/grpc/credentials"
+
+	"github.com/MasteryConnect/unicreds/server/pb"
+)
+
+// ListenConfig configures the transport a Server is served over.
+type ListenConfig struct {
+	// Network and Address are passed to net.Listen, e.g. ("unix",
+	// "/var/run/unicreds.sock") or ("tcp", "0.0.0.0:9443").
+	Network string
+	Address string
+
+	// TLS, when set, terminates mTLS on the gRPC listener. Leave nil for a
+	// Unix socket relying on filesystem permissions instead.
+	TLS *tls.Config
+
+	// HTTPAddress, when non-empty, additionally serves the REST gateway
+	// (see gateway.go) on this address.
+	HTTPAddress string
+}
+
+// Listen starts srv as a gRPC daemon per cfg and blocks until the listener
+// errors or is closed. Run it in its own goroutine per transport when both a
+// gRPC and a gateway listener are configured.
+func Listen(srv *Server, cfg ListenConfig) error {
+	lis, err := net.Listen(cfg.Network, cfg.Address)
+	if err != nil {
+		return err
+	}
+
+	var opts []grpc.ServerOption
+	if cfg.TLS != nil {
+		opts = append(opts, grpc.Creds(grpccredentials.NewTLS(cfg.TLS)))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+	pb.RegisterCredentialsServer(grpcServer, srv)
+
+	if cfg.HTTPAddress != "" {
+		gw := newGateway(srv, cfg.TLS)
+		go func() {
+			log.WithField("addr", cfg.HTTPAddress).Info("serving REST gateway")
+			if err := gw.ListenAndServe(cfg.HTTPAddress); err != nil {
+				log.WithError(err).Error("REST gateway stopped")
+			}
+		}()
+	}
+
+	log.WithFields(log.Fields{"network": cfg.Network, "address": cfg.Address}).Info("serving gRPC")
+
+	return grpcServer.Serve(lis)
+}
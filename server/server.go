@@ -0,0 +1,207 @@
+// Package server implements a gRPC daemon that exposes the unicreds
+// credential store as a local service, so that applications can fetch
+// secrets over a Unix socket or mTLS endpoint instead of each embedding AWS
+// SDKs and KMS permissions of their own.
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/apex/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/MasteryConnect/unicreds"
+	"github.com/MasteryConnect/unicreds/server/pb"
+)
+
+// Config configures a Server.
+type Config struct {
+	// Client is the unicreds client the Server reads and writes secrets
+	// through.
+	Client *unicreds.Client
+
+	// TableName is the DynamoDB table Client is pointed at.
+	TableName *string
+
+	// Auth authorizes each RPC against the secret name it touches. It must
+	// not be nil.
+	Auth Authenticator
+
+	// CacheTTL is how long a decrypted plaintext is kept in memory after a
+	// Get, to save repeat KMS calls. 0 disables caching. This is separate
+	// from the DynamoDB-side expires_at TTL feature.
+	CacheTTL time.Duration
+}
+
+// Server implements pb.CredentialsServer against a unicreds.Client.
+type Server struct {
+	pb.UnimplementedCredentialsServer
+
+	client    *unicreds.Client
+	tableName *string
+	auth      Authenticator
+	cache     *plaintextCache
+}
+
+// New builds a Server from cfg.
+func New(cfg Config) *Server {
+	return &Server{
+		client:    cfg.Client,
+		tableName: cfg.TableName,
+		auth:      cfg.Auth,
+		cache:     newPlaintextCache(cfg.CacheTTL),
+	}
+}
+
+func (s *Server) authorize(ctx context.Context, name string) error {
+	prefixes, err := s.auth.Authorize(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if !authorized(prefixes, name) {
+		return status.Errorf(codes.PermissionDenied, "not authorized for %q", name)
+	}
+
+	return nil
+}
+
+// Get implements pb.CredentialsServer.
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	if err := s.authorize(ctx, req.Name); err != nil {
+		return nil, err
+	}
+
+	key := cacheKey(req.Name, req.Version)
+	if cachedCred, secret, ok := s.cache.get(req.Name, key); ok {
+		return &pb.GetResponse{Credential: &pb.DecryptedCredential{
+			Credential: toPB(cachedCred),
+			Secret:     secret,
+		}}, nil
+	}
+
+	cred, err := s.client.GetSecret(ctx, s.tableName, req.Name, req.Version)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	s.cache.put(req.Name, key, cred.Credential, cred.Secret)
+
+	return &pb.GetResponse{Credential: toDecryptedPB(cred)}, nil
+}
+
+// Put implements pb.CredentialsServer.
+func (s *Server) Put(ctx context.Context, req *pb.PutRequest) (*pb.PutResponse, error) {
+	if err := s.authorize(ctx, req.Name); err != nil {
+		return nil, err
+	}
+
+	version, err := s.client.ResolveVersion(ctx, s.tableName, req.Name, 0)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if req.Version != "" {
+		version = req.Version
+	}
+
+	ttl := time.Duration(req.TtlSeconds) * time.Second
+
+	if err := s.client.PutSecret(ctx, s.tableName, req.Alias, req.Name, req.Secret, version, ttl); err != nil {
+		return nil, toStatus(err)
+	}
+
+	s.cache.evict(req.Name, cacheKey(req.Name, version))
+	s.cache.evict(req.Name, cacheKey(req.Name, ""))
+
+	return &pb.PutResponse{Version: version}, nil
+}
+
+// List implements pb.CredentialsServer.
+func (s *Server) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	if err := s.authorize(ctx, ""); err != nil {
+		return nil, err
+	}
+
+	creds, err := s.client.ListSecrets(ctx, s.tableName, req.AllVersions)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	out := &pb.ListResponse{Credentials: make([]*pb.Credential, 0, len(creds))}
+	for _, cred := range creds {
+		out.Credentials = append(out.Credentials, toPB(cred))
+	}
+
+	return out, nil
+}
+
+// Delete implements pb.CredentialsServer.
+func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	if err := s.authorize(ctx, req.Name); err != nil {
+		return nil, err
+	}
+
+	if err := s.client.DeleteSecret(ctx, s.tableName, req.Name); err != nil {
+		return nil, toStatus(err)
+	}
+
+	// DeleteSecret removes every version of req.Name, so every
+	// version-specific cache entry for it (not just the "latest" one) must
+	// go too, or a Get for a previously-cached specific version would keep
+	// serving deleted plaintext until CacheTTL elapses.
+	s.cache.evictName(req.Name)
+
+	return &pb.DeleteResponse{}, nil
+}
+
+// Rotate implements pb.CredentialsServer, re-encrypting name under the next
+// version.
+func (s *Server) Rotate(ctx context.Context, req *pb.RotateRequest) (*pb.RotateResponse, error) {
+	if err := s.authorize(ctx, req.Name); err != nil {
+		return nil, err
+	}
+
+	version, err := s.client.ResolveVersion(ctx, s.tableName, req.Name, 0)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if err := s.client.PutSecret(ctx, s.tableName, req.Alias, req.Name, req.Secret, version, 0); err != nil {
+		return nil, toStatus(err)
+	}
+
+	s.cache.evict(req.Name, cacheKey(req.Name, ""))
+
+	log.WithFields(log.Fields{"name": req.Name, "version": version}).Info("rotated")
+
+	return &pb.RotateResponse{Version: version}, nil
+}
+
+func toStatus(err error) error {
+	switch err {
+	case unicreds.ErrSecretNotFound, unicreds.ErrSecretExpired:
+		return status.Error(codes.NotFound, err.Error())
+	case unicreds.ErrHmacValidationFailed:
+		return status.Error(codes.DataLoss, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toPB(cred *unicreds.Credential) *pb.Credential {
+	return &pb.Credential{
+		Name:      cred.Name,
+		Version:   cred.Version,
+		CreatedAt: cred.CreatedAt,
+		ExpiresAt: cred.ExpiresAt,
+	}
+}
+
+func toDecryptedPB(cred *unicreds.DecryptedCredential) *pb.DecryptedCredential {
+	return &pb.DecryptedCredential{
+		Credential: toPB(cred.Credential),
+		Secret:     cred.Secret,
+	}
+}
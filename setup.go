@@ -0,0 +1,321 @@
+package unicreds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apex/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	asctypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Billing modes accepted by SetupOptions.BillingMode. The zero value is
+// BillingModeProvisioned, matching Setup's historical 1/1 capacity
+// behaviour.
+const (
+	BillingModeProvisioned   = types.BillingModeProvisioned
+	BillingModePayPerRequest = types.BillingModePayPerRequest
+)
+
+// defaultAutoScalingTargetUtilization is applied when an AutoScalingPolicy
+// leaves TargetUtilization unset.
+const defaultAutoScalingTargetUtilization = 70.0
+
+// ErrAutoScalingRequiresProvisionedBilling returned when SetupOptions sets
+// AutoScaling alongside BillingModePayPerRequest, which has no capacity to
+// scale.
+var ErrAutoScalingRequiresProvisionedBilling = errors.New("auto scaling requires provisioned billing mode")
+
+// ApplicationAutoScalingAPI is the subset of the aws-sdk-go-v2 application
+// auto scaling client that unicreds uses to register target-tracking
+// scaling policies for a table created by Setup.
+type ApplicationAutoScalingAPI interface {
+	RegisterScalableTarget(ctx context.Context, params *applicationautoscaling.RegisterScalableTargetInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.RegisterScalableTargetOutput, error)
+	PutScalingPolicy(ctx context.Context, params *applicationautoscaling.PutScalingPolicyInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.PutScalingPolicyOutput, error)
+	DescribeScalableTargets(ctx context.Context, params *applicationautoscaling.DescribeScalableTargetsInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.DescribeScalableTargetsOutput, error)
+}
+
+// AutoScalingPolicy describes a target-tracking auto scaling policy that
+// Setup registers for both the read and write capacity dimensions of a
+// provisioned table once it becomes active.
+type AutoScalingPolicy struct {
+	// MinCapacity and MaxCapacity bound the registered scalable target for
+	// both dimensions.
+	MinCapacity int32
+	MaxCapacity int32
+
+	// TargetUtilization is the target-tracking utilization percentage. A
+	// zero value defaults to 70.
+	TargetUtilization float64
+}
+
+// SetupOptions configures the billing mode, capacity and auto scaling that
+// Setup uses when creating the credential table.
+type SetupOptions struct {
+	// BillingMode selects on-demand (BillingModePayPerRequest) or
+	// provisioned capacity. The zero value behaves as
+	// BillingModeProvisioned.
+	BillingMode types.BillingMode
+
+	// ReadCapacity and WriteCapacity are only used when BillingMode is
+	// BillingModeProvisioned. A zero value defaults to 1, matching Setup's
+	// historical behaviour.
+	ReadCapacity  int64
+	WriteCapacity int64
+
+	// AutoScaling, if set, registers a target-tracking scaling policy for
+	// the table's read and write capacity after it becomes active. Only
+	// valid alongside BillingModeProvisioned.
+	AutoScaling *AutoScalingPolicy
+}
+
+// Setup create the table which stores credentials
+func Setup(ctx context.Context, tableName *string, opts SetupOptions) error {
+	return defaultClient.Setup(ctx, tableName, opts)
+}
+
+// Setup create the table which stores credentials
+func (c *Client) Setup(ctx context.Context, tableName *string, opts SetupOptions) (err error) {
+	log.Debug("Running Setup")
+
+	billingMode := opts.BillingMode
+	if billingMode == "" {
+		billingMode = BillingModeProvisioned
+	}
+
+	if billingMode != BillingModeProvisioned && opts.AutoScaling != nil {
+		return ErrAutoScalingRequiresProvisionedBilling
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("name"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("version"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("name"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("version"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		BillingMode: billingMode,
+		TableName:   tableName,
+	}
+
+	if billingMode == BillingModeProvisioned {
+		readCapacity := opts.ReadCapacity
+		if readCapacity == 0 {
+			readCapacity = 1
+		}
+		writeCapacity := opts.WriteCapacity
+		if writeCapacity == 0 {
+			writeCapacity = 1
+		}
+		input.ProvisionedThroughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(readCapacity),
+			WriteCapacityUnits: aws.Int64(writeCapacity),
+		}
+	}
+
+	_, err = c.writeDdb.CreateTable(ctx, input)
+	if err != nil {
+		return
+	}
+
+	err = c.waitForTable(ctx, tableName, waitForTableConfig{
+		enableTTL:   true,
+		autoScaling: opts.AutoScaling,
+	})
+
+	return
+}
+
+// waitForTableConfig controls which of Setup's sub-resources waitForTable
+// provisions and polls for readiness once the table itself becomes ACTIVE.
+type waitForTableConfig struct {
+	enableTTL   bool
+	autoScaling *AutoScalingPolicy
+}
+
+// setupTimeoutError reports which sub-resource failed to become ready
+// within waitForTable's budget: "table", "ttl" or "autoscaling".
+type setupTimeoutError struct {
+	Resource string
+}
+
+func (e *setupTimeoutError) Error() string {
+	return fmt.Sprintf("timed out waiting for %s to become ready", e.Resource)
+}
+
+// waitForTable waits for tableName to become ACTIVE and then, depending on
+// cfg, enables TTL and/or registers auto scaling and waits for each to
+// settle. All of it shares the same tableCreateTimeout budget: whichever
+// sub-resource hasn't converged once the deadline passes is named in the
+// returned error.
+func (c *Client) waitForTable(ctx context.Context, tableName *string, cfg waitForTableConfig) error {
+	deadline := time.Now().Add(tableCreateTimeout)
+
+	if err := c.pollUntil(ctx, deadline, "table", func() (bool, error) {
+		res, err := c.writeDdb.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: tableName,
+		})
+		if err != nil {
+			return false, err
+		}
+		return res.Table.TableStatus == types.TableStatusActive, nil
+	}); err != nil {
+		return err
+	}
+
+	if cfg.enableTTL {
+		_, err := c.writeDdb.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+			TableName: tableName,
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: aws.String(ttlAttribute),
+				Enabled:       aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := c.pollUntil(ctx, deadline, "ttl", func() (bool, error) {
+			res, err := c.writeDdb.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+				TableName: tableName,
+			})
+			if err != nil {
+				return false, err
+			}
+			return res.TimeToLiveDescription.TimeToLiveStatus == types.TimeToLiveStatusEnabled, nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	if cfg.autoScaling != nil {
+		if err := c.registerAutoScaling(ctx, tableName, cfg.autoScaling); err != nil {
+			return err
+		}
+
+		if err := c.pollUntil(ctx, deadline, "autoscaling", func() (bool, error) {
+			return c.scalingTargetsReady(ctx, tableName)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pollUntil calls check once a second until it reports ready, ctx is
+// cancelled, or deadline passes, in which case it returns a
+// setupTimeoutError naming resource.
+func (c *Client) pollUntil(ctx context.Context, deadline time.Time, resource string, check func() (bool, error)) error {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		ready, err := check()
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+			if time.Now().After(deadline) {
+				return &setupTimeoutError{Resource: resource}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// autoScalingDimensions pairs each DynamoDB capacity dimension with the
+// scalable dimension and predefined metric RegisterScalableTarget/
+// PutScalingPolicy need to target it.
+var autoScalingDimensions = []struct {
+	name     string
+	scalable asctypes.ScalableDimension
+	metric   asctypes.MetricType
+}{
+	{"read", asctypes.ScalableDimensionDynamoDBTableReadCapacityUnits, asctypes.MetricTypeDynamoDBReadCapacityUtilization},
+	{"write", asctypes.ScalableDimensionDynamoDBTableWriteCapacityUnits, asctypes.MetricTypeDynamoDBWriteCapacityUtilization},
+}
+
+// registerAutoScaling registers a scalable target and target-tracking
+// scaling policy, defaulting to defaultAutoScalingTargetUtilization percent,
+// for both the read and write capacity dimensions of tableName.
+func (c *Client) registerAutoScaling(ctx context.Context, tableName *string, policy *AutoScalingPolicy) error {
+	resourceID := fmt.Sprintf("table/%s", aws.ToString(tableName))
+
+	targetValue := policy.TargetUtilization
+	if targetValue == 0 {
+		targetValue = defaultAutoScalingTargetUtilization
+	}
+
+	for _, dim := range autoScalingDimensions {
+		_, err := c.appScaling.RegisterScalableTarget(ctx, &applicationautoscaling.RegisterScalableTargetInput{
+			ServiceNamespace:  asctypes.ServiceNamespaceDynamodb,
+			ResourceId:        aws.String(resourceID),
+			ScalableDimension: dim.scalable,
+			MinCapacity:       aws.Int32(policy.MinCapacity),
+			MaxCapacity:       aws.Int32(policy.MaxCapacity),
+		})
+		if err != nil {
+			return fmt.Errorf("registering %s auto scaling target: %w", dim.name, err)
+		}
+
+		_, err = c.appScaling.PutScalingPolicy(ctx, &applicationautoscaling.PutScalingPolicyInput{
+			ServiceNamespace:  asctypes.ServiceNamespaceDynamodb,
+			ResourceId:        aws.String(resourceID),
+			ScalableDimension: dim.scalable,
+			PolicyName:        aws.String(fmt.Sprintf("%s-%s-target-tracking", aws.ToString(tableName), dim.name)),
+			PolicyType:        asctypes.PolicyTypeTargetTrackingScaling,
+			TargetTrackingScalingPolicyConfiguration: &asctypes.TargetTrackingScalingPolicyConfiguration{
+				TargetValue: aws.Float64(targetValue),
+				PredefinedMetricSpecification: &asctypes.PredefinedMetricSpecification{
+					PredefinedMetricType: dim.metric,
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("putting %s auto scaling policy: %w", dim.name, err)
+		}
+	}
+
+	return nil
+}
+
+// scalingTargetsReady reports whether application auto scaling has
+// finished registering scalable targets for both capacity dimensions of
+// tableName.
+func (c *Client) scalingTargetsReady(ctx context.Context, tableName *string) (bool, error) {
+	res, err := c.appScaling.DescribeScalableTargets(ctx, &applicationautoscaling.DescribeScalableTargetsInput{
+		ServiceNamespace: asctypes.ServiceNamespaceDynamodb,
+		ResourceIds:      []string{fmt.Sprintf("table/%s", aws.ToString(tableName))},
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(res.ScalableTargets) == len(autoScalingDimensions), nil
+}
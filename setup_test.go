@@ -0,0 +1,165 @@
+package unicreds
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	asctypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// mockSetupDynamoDB implements DynamoDBAPI, panicking on any method a test
+// didn't stub out.
+type mockSetupDynamoDB struct {
+	DynamoDBAPI
+
+	describeTableFn func(ctx context.Context, params *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error)
+	updateTTLFn     func(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error)
+	describeTTLFn   func(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput) (*dynamodb.DescribeTimeToLiveOutput, error)
+}
+
+func (m *mockSetupDynamoDB) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return m.describeTableFn(ctx, params)
+}
+
+func (m *mockSetupDynamoDB) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	return m.updateTTLFn(ctx, params)
+}
+
+func (m *mockSetupDynamoDB) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	return m.describeTTLFn(ctx, params)
+}
+
+// mockAppScaling implements ApplicationAutoScalingAPI, panicking on any
+// method a test didn't stub out.
+type mockAppScaling struct {
+	ApplicationAutoScalingAPI
+
+	registerScalableTargetFn  func(ctx context.Context, params *applicationautoscaling.RegisterScalableTargetInput) (*applicationautoscaling.RegisterScalableTargetOutput, error)
+	putScalingPolicyFn        func(ctx context.Context, params *applicationautoscaling.PutScalingPolicyInput) (*applicationautoscaling.PutScalingPolicyOutput, error)
+	describeScalableTargetsFn func(ctx context.Context, params *applicationautoscaling.DescribeScalableTargetsInput) (*applicationautoscaling.DescribeScalableTargetsOutput, error)
+}
+
+func (m *mockAppScaling) RegisterScalableTarget(ctx context.Context, params *applicationautoscaling.RegisterScalableTargetInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.RegisterScalableTargetOutput, error) {
+	return m.registerScalableTargetFn(ctx, params)
+}
+
+func (m *mockAppScaling) PutScalingPolicy(ctx context.Context, params *applicationautoscaling.PutScalingPolicyInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.PutScalingPolicyOutput, error) {
+	return m.putScalingPolicyFn(ctx, params)
+}
+
+func (m *mockAppScaling) DescribeScalableTargets(ctx context.Context, params *applicationautoscaling.DescribeScalableTargetsInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.DescribeScalableTargetsOutput, error) {
+	return m.describeScalableTargetsFn(ctx, params)
+}
+
+func TestWaitForTableEnablesTTL(t *testing.T) {
+	var gotUpdateTTL *dynamodb.UpdateTimeToLiveInput
+
+	c := &Client{
+		writeDdb: &mockSetupDynamoDB{
+			describeTableFn: func(ctx context.Context, params *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+				return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusActive}}, nil
+			},
+			updateTTLFn: func(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error) {
+				gotUpdateTTL = params
+				return &dynamodb.UpdateTimeToLiveOutput{}, nil
+			},
+			describeTTLFn: func(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput) (*dynamodb.DescribeTimeToLiveOutput, error) {
+				return &dynamodb.DescribeTimeToLiveOutput{
+					TimeToLiveDescription: &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusEnabled},
+				}, nil
+			},
+		},
+	}
+
+	if err := c.waitForTable(context.Background(), stringPtr("table"), waitForTableConfig{enableTTL: true}); err != nil {
+		t.Fatalf("waitForTable: %v", err)
+	}
+	if gotUpdateTTL == nil {
+		t.Fatal("expected UpdateTimeToLive to be called")
+	}
+	if !*gotUpdateTTL.TimeToLiveSpecification.Enabled {
+		t.Fatal("expected TimeToLiveSpecification.Enabled to be true")
+	}
+}
+
+func TestPollUntilTimesOutOnPendingCheck(t *testing.T) {
+	c := &Client{}
+
+	// An already-past deadline means pollUntil's first tick trips the
+	// timeout instead of waiting a full second for it.
+	deadline := time.Now().Add(-time.Second)
+
+	err := c.pollUntil(context.Background(), deadline, "ttl", func() (bool, error) {
+		return false, nil
+	})
+
+	var timeoutErr *setupTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Resource != "ttl" {
+		t.Fatalf("expected a setupTimeoutError naming \"ttl\", got %v", err)
+	}
+}
+
+func TestRegisterAutoScalingRegistersBothDimensions(t *testing.T) {
+	var registered []asctypes.ScalableDimension
+
+	c := &Client{
+		appScaling: &mockAppScaling{
+			registerScalableTargetFn: func(ctx context.Context, params *applicationautoscaling.RegisterScalableTargetInput) (*applicationautoscaling.RegisterScalableTargetOutput, error) {
+				registered = append(registered, params.ScalableDimension)
+				return &applicationautoscaling.RegisterScalableTargetOutput{}, nil
+			},
+			putScalingPolicyFn: func(ctx context.Context, params *applicationautoscaling.PutScalingPolicyInput) (*applicationautoscaling.PutScalingPolicyOutput, error) {
+				if *params.TargetTrackingScalingPolicyConfiguration.TargetValue != defaultAutoScalingTargetUtilization {
+					t.Fatalf("expected default target utilization, got %v", *params.TargetTrackingScalingPolicyConfiguration.TargetValue)
+				}
+				return &applicationautoscaling.PutScalingPolicyOutput{}, nil
+			},
+		},
+	}
+
+	policy := &AutoScalingPolicy{MinCapacity: 1, MaxCapacity: 10}
+	if err := c.registerAutoScaling(context.Background(), stringPtr("table"), policy); err != nil {
+		t.Fatalf("registerAutoScaling: %v", err)
+	}
+
+	if len(registered) != 2 {
+		t.Fatalf("expected both read and write dimensions registered, got %v", registered)
+	}
+}
+
+func TestScalingTargetsReady(t *testing.T) {
+	c := &Client{
+		appScaling: &mockAppScaling{
+			describeScalableTargetsFn: func(ctx context.Context, params *applicationautoscaling.DescribeScalableTargetsInput) (*applicationautoscaling.DescribeScalableTargetsOutput, error) {
+				return &applicationautoscaling.DescribeScalableTargetsOutput{
+					ScalableTargets: []asctypes.ScalableTarget{{}, {}},
+				}, nil
+			},
+		},
+	}
+
+	ready, err := c.scalingTargetsReady(context.Background(), stringPtr("table"))
+	if err != nil {
+		t.Fatalf("scalingTargetsReady: %v", err)
+	}
+	if !ready {
+		t.Fatal("expected ready once both dimensions are registered")
+	}
+}
+
+func TestSetupRejectsAutoScalingWithPayPerRequest(t *testing.T) {
+	c := &Client{}
+
+	err := c.Setup(context.Background(), stringPtr("table"), SetupOptions{
+		BillingMode: BillingModePayPerRequest,
+		AutoScaling: &AutoScalingPolicy{MinCapacity: 1, MaxCapacity: 10},
+	})
+	if err != ErrAutoScalingRequiresProvisionedBilling {
+		t.Fatalf("expected ErrAutoScalingRequiresProvisionedBilling, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package unicreds
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// TableFormat selects how Table renders its rows.
+type TableFormat int
+
+const (
+	// TableFormatText renders an aligned, human-readable table. This is the
+	// default.
+	TableFormatText TableFormat = iota
+
+	// TableFormatCSV renders rows as comma-separated values.
+	TableFormatCSV
+)
+
+// Table buffers headers and rows for the CLI's list/getall output, then
+// renders them as either an aligned text table or CSV.
+type Table struct {
+	out     io.Writer
+	format  TableFormat
+	headers []string
+	rows    [][]string
+}
+
+// NewTable builds a Table that renders to out.
+func NewTable(out io.Writer) *Table {
+	return &Table{out: out}
+}
+
+// SetFormat selects the render format. The zero value is TableFormatText.
+func (t *Table) SetFormat(format TableFormat) {
+	t.format = format
+}
+
+// SetHeaders sets the column headers rendered above the rows.
+func (t *Table) SetHeaders(headers []string) {
+	t.headers = headers
+}
+
+// Write appends a row. Columns beyond the header count, or missing
+// relative to it, are rendered as-is.
+func (t *Table) Write(row []string) {
+	t.rows = append(t.rows, row)
+}
+
+// Render writes the buffered headers and rows to the Table's writer.
+func (t *Table) Render() error {
+	switch t.format {
+	case TableFormatCSV:
+		return t.renderCSV()
+	default:
+		return t.renderText()
+	}
+}
+
+func (t *Table) renderCSV() error {
+	w := csv.NewWriter(t.out)
+
+	if len(t.headers) > 0 {
+		if err := w.Write(t.headers); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range t.rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func (t *Table) renderText() error {
+	w := tabwriter.NewWriter(t.out, 0, 4, 2, ' ', 0)
+
+	if len(t.headers) > 0 {
+		if _, err := io.WriteString(w, strings.Join(t.headers, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range t.rows {
+		if _, err := io.WriteString(w, strings.Join(row, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}